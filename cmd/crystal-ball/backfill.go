@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/orakurudata/crystal-ball/contracts"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const (
+	// CheckpointKey is the kv key of the last block whose events were processed
+	CheckpointKey = "last_processed_block"
+	// CheckpointInterval defines how often the checkpoint is moved while the subscription is alive
+	CheckpointInterval = 1 * time.Minute
+	// BackfillLimit is the maximum amount of blocks scanned for missed events, which is about 2 days on BSC
+	BackfillLimit = 50000
+	// BackfillPageSize is the amount of blocks requested at once, most endpoints limit log queries to 5000 blocks
+	BackfillPageSize = 4000
+)
+
+// BackfillRetryDelay is the delay before the first retry of a failed backfill, every next retry waits longer
+var BackfillRetryDelay = 5 * time.Second
+
+// loadCheckpoint returns the last processed block, or false if the node was never started with this database
+func (n *Node) loadCheckpoint() (uint64, bool) {
+	block, err := n.DB.GetInt(CheckpointKey)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Error().Err(err).Caller().Msg("could not read checkpoint")
+		}
+		return 0, false
+	}
+	return uint64(block), true
+}
+
+// advanceCheckpoint stores block as the last processed one, unless missed events are still being scanned
+func (n *Node) advanceCheckpoint(block uint64) {
+	n.checkpointMutex.Lock()
+	defer n.checkpointMutex.Unlock()
+	if n.backfilling || block <= n.checkpointBlock {
+		return
+	}
+	err := n.DB.SetInt(CheckpointKey, int64(block))
+	if err != nil {
+		log.Error().Err(err).Caller().Msg("could not store checkpoint")
+		return
+	}
+	n.checkpointBlock = block
+}
+
+// backfill scans blocks between the checkpoint and the head for Requested events, which could be missed while
+// the node was offline or its subscription was broken. Events are pushed into sink, duplicates are ignored by the handler.
+// Checkpoint stays frozen until the scan succeeds, failed scans are retried until ctx is canceled.
+func (n *Node) backfill(ctx context.Context, sink chan<- *contracts.IOrakuruCoreRequested) {
+	n.checkpointMutex.Lock()
+	n.backfilling = true
+	n.checkpointMutex.Unlock()
+
+	backoff := BackfillRetryDelay
+	for {
+		head, err := n.backfillEvents(ctx, sink)
+		if err == nil {
+			n.finishBackfill(head)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error().Err(err).Caller().Msg("could not backfill events")
+		log.Warn().Dur("backoff", backoff).Msg("waiting before trying again")
+		if sleepUntil(ctx, time.Now().Add(backoff)) != nil {
+			return
+		}
+		backoff += BackfillRetryDelay
+	}
+}
+
+// backfillEvents pushes missed events into sink and returns the last scanned block
+func (n *Node) backfillEvents(ctx context.Context, sink chan<- *contracts.IOrakuruCoreRequested) (uint64, error) {
+	head, err := n.Client.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	from, ok := n.loadCheckpoint()
+	if !ok {
+		// Pending requests are already reloaded, there's nothing to scan on the first start
+		return head, nil
+	}
+	if head > BackfillLimit && from < head-BackfillLimit {
+		log.Warn().Uint64("checkpoint", from).Uint64("head", head).Msg("checkpoint is too old, some events can't be backfilled")
+		from = head - BackfillLimit
+	}
+	if from > head {
+		return head, nil
+	}
+
+	events, err := n.collectEvents(ctx, from, head)
+	if err != nil {
+		return 0, err
+	}
+	for _, ev := range events {
+		select {
+		case sink <- ev:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	log.Debug().Uint64("from", from).Uint64("to", head).Int("events", len(events)).Msg("missed events were backfilled")
+	return head, nil
+}
+
+// finishBackfill lets the checkpoint move again, starting from head
+func (n *Node) finishBackfill(head uint64) {
+	n.checkpointMutex.Lock()
+	n.backfilling = false
+	n.checkpointMutex.Unlock()
+	n.advanceCheckpoint(head)
+}
+
+// collectEvents returns Requested events emitted between start and end blocks inclusively
+func (n *Node) collectEvents(ctx context.Context, start, end uint64) ([]*contracts.IOrakuruCoreRequested, error) {
+	var out []*contracts.IOrakuruCoreRequested
+	for i := start; i <= end; i += BackfillPageSize {
+		pageEnd := i + BackfillPageSize - 1
+		if pageEnd > end {
+			pageEnd = end
+		}
+		iter, err := n.core().FilterRequested(&bind.FilterOpts{
+			Start:   i,
+			End:     &pageEnd,
+			Context: ctx,
+		}, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		for iter.Next() {
+			out = append(out, iter.Event)
+		}
+		err = iter.Error()
+		_ = iter.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrInvalidDataSource       = errors.New("invalid data source")
+	ErrUnsupportedSourceMethod = errors.New("unsupported data source method")
+	ErrSecretRedirect          = errors.New("refusing to follow redirect to another host with secrets")
+)
+
+// DataSource describes HTTP request that is made to obtain the data.
+// Data source of a request is either a plain URL, which is requested with GET,
+// or a JSON object with "url", "method", "headers" and "body" fields.
+// Body can be either a string, which is sent as is, or any other JSON value, which is sent as JSON.
+type DataSource struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+
+	// body contains Body decoded into bytes that will be sent
+	body string
+	// jsonBody is set when body is a JSON value and not a plain string
+	jsonBody bool
+	// secretHost is the host substituted secrets were checked against, redirects to other hosts are rejected
+	secretHost string
+}
+
+// secretHostKey is the request context key holding DataSource.secretHost
+type secretHostKey struct{}
+
+// ParseDataSource decodes data source from the request
+func ParseDataSource(source string) (*DataSource, error) {
+	if !strings.HasPrefix(strings.TrimSpace(source), "{") {
+		return &DataSource{URL: source, Method: http.MethodGet}, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(source))
+	dec.DisallowUnknownFields()
+	ds := &DataSource{}
+	err := dec.Decode(ds)
+	if err != nil {
+		return nil, err
+	}
+	if ds.URL == "" {
+		return nil, ErrInvalidDataSource
+	}
+	ds.Method = strings.ToUpper(ds.Method)
+	if ds.Method == "" {
+		ds.Method = http.MethodGet
+	}
+	if ds.Method != http.MethodGet && ds.Method != http.MethodPost {
+		return nil, ErrUnsupportedSourceMethod
+	}
+	body := bytes.TrimSpace(ds.Body)
+	if len(body) != 0 && !bytes.Equal(body, []byte("null")) {
+		if ds.Method == http.MethodGet {
+			return nil, ErrInvalidDataSource
+		}
+		if body[0] == '"' {
+			err = json.Unmarshal(body, &ds.body)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			ds.body = string(body)
+			ds.jsonBody = true
+		}
+	}
+	return ds, nil
+}
+
+// unwrapDataSourceSecrets replaces secrets in URL, headers and body of the data source.
+// All of them are sent to the host of the unwrapped URL, so secrets bound to a host are checked against it.
+func (n *Node) unwrapDataSourceSecrets(ds *DataSource) (*DataSource, error) {
+	out := *ds
+	var bindings, b []string
+	var err error
+	out.URL, bindings, err = n.unwrapSecrets(ds.URL, identity)
+	if err != nil {
+		return nil, err
+	}
+	out.Headers = make(map[string]string, len(ds.Headers))
+	for k, v := range ds.Headers {
+		out.Headers[k], b, err = n.unwrapSecrets(v, identity)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, b...)
+	}
+	if ds.jsonBody {
+		// Secrets are located inside JSON strings, so their values have to be escaped
+		out.body, b, err = n.unwrapSecrets(ds.body, escapeJSONString)
+	} else {
+		out.body, b, err = n.unwrapSecrets(ds.body, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	bindings = append(bindings, b...)
+	err = n.checkBindings(bindings, urlHost(out.URL))
+	if err != nil {
+		return nil, err
+	}
+	if len(bindings) != 0 {
+		out.secretHost = urlHost(out.URL)
+	}
+	return &out, nil
+}
+
+// NewRequest creates HTTP request described by the data source
+func (ds *DataSource) NewRequest(ctx context.Context) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(ds.requestContext(ctx), ds.Method, ds.URL, strings.NewReader(ds.body))
+	if err != nil {
+		return nil, err
+	}
+	if ds.jsonBody {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range ds.Headers {
+		r.Header.Set(k, v)
+	}
+	return r, nil
+}
+
+// requestContext marks ctx with the host substituted secrets are bound to, so that checkRedirect can reject leaving it
+func (ds *DataSource) requestContext(ctx context.Context) context.Context {
+	if ds.secretHost == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, secretHostKey{}, ds.secretHost)
+}
+
+// checkRedirect rejects redirects of requests with secrets to another host, since the secrets would be sent
+// there in URL, headers or body. Other redirects are followed the same way as by default.
+func checkRedirect(r *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	host, ok := via[0].Context().Value(secretHostKey{}).(string)
+	if ok && r.URL.Hostname() != host {
+		log.Warn().Str("host", r.URL.Hostname()).Str("binding", host).Msg("refusing to follow redirect with secrets to another host")
+		return ErrSecretRedirect
+	}
+	return nil
+}
+
+func escapeJSONString(value string) string {
+	data, _ := json.Marshal(value)
+	return string(data[1 : len(data)-1])
+}
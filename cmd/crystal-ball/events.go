@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/orakurudata/crystal-ball/configuration"
+	"github.com/orakurudata/crystal-ball/contracts"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// eventSource returns the configured event source, resolving "auto" by capabilities of endpoints
+func (n *Node) eventSource() string {
+	if n.Web3.Events.Source != configuration.EventSourceAuto {
+		return n.Web3.Events.Source
+	}
+	if n.Client.CanSubscribe() {
+		return configuration.EventSourceSubscribe
+	}
+	return configuration.EventSourcePoll
+}
+
+// mergeSubscriptions combines subscriptions into a single one, which fails as soon as any of them fails
+func mergeSubscriptions(subs ...event.Subscription) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer func() {
+			for _, sub := range subs {
+				sub.Unsubscribe()
+			}
+		}()
+		errs := make(chan error, len(subs))
+		for _, sub := range subs {
+			go func(sub event.Subscription) {
+				// Err channel is closed when subscription is unsubscribed
+				if err, ok := <-sub.Err(); ok {
+					errs <- err
+				}
+			}(sub)
+		}
+		select {
+		case err := <-errs:
+			return err
+		case <-quit:
+			return nil
+		}
+	})
+}
+
+// pollEvents is an alternative to subscriptions for endpoints that don't support them.
+// It queries logs of new blocks every poll interval and pushes events into the same sinks.
+func (n *Node) pollEvents(sink chan<- *contracts.IOrakuruCoreRequested, canceled chan<- *contracts.IOrakuruCoreCanceled,
+	fulfilled chan<- *contracts.IOrakuruCoreFulfilled) (event.Subscription, error) {
+	next, err := n.nextPolledBlock()
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-quit:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		ticker := time.NewTicker(n.Web3.Events.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return nil
+			}
+			head, err := n.Client.BlockNumber(ctx)
+			if err != nil {
+				return err
+			}
+			for next <= head {
+				end := next + BackfillPageSize - 1
+				if end > head {
+					end = head
+				}
+				err = n.pollRange(ctx, next, end, sink, canceled, fulfilled)
+				if ctx.Err() != nil {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				log.Trace().Uint64("from", next).Uint64("to", end).Msg("polled new events")
+				next = end + 1
+				n.setPolledBlock(next)
+			}
+		}
+	}), nil
+}
+
+// nextPolledBlock returns the block polling starts from. Polling resumes where it stopped before,
+// so that events are not missed when polling fails, and starts from the head otherwise.
+func (n *Node) nextPolledBlock() (uint64, error) {
+	n.checkpointMutex.Lock()
+	next := n.pollNext
+	n.checkpointMutex.Unlock()
+	if next != 0 {
+		return next, nil
+	}
+	head, err := n.Client.BlockNumber(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	n.setPolledBlock(head)
+	return head, nil
+}
+
+// setPolledBlock remembers the first block that wasn't polled yet
+func (n *Node) setPolledBlock(next uint64) {
+	n.checkpointMutex.Lock()
+	defer n.checkpointMutex.Unlock()
+	n.pollNext = next
+}
+
+// pollRange pushes events emitted between start and end blocks inclusively into sinks
+func (n *Node) pollRange(ctx context.Context, start, end uint64, sink chan<- *contracts.IOrakuruCoreRequested,
+	canceled chan<- *contracts.IOrakuruCoreCanceled, fulfilled chan<- *contracts.IOrakuruCoreFulfilled) error {
+	opts := &bind.FilterOpts{Start: start, End: &end, Context: ctx}
+	requestedIter, err := n.core().FilterRequested(opts, nil, nil)
+	if err != nil {
+		return err
+	}
+	for requestedIter.Next() {
+		select {
+		case sink <- requestedIter.Event:
+		case <-ctx.Done():
+		}
+	}
+	err = requestedIter.Error()
+	_ = requestedIter.Close()
+	if err != nil {
+		return err
+	}
+
+	canceledIter, err := n.core().FilterCanceled(opts, nil)
+	if err != nil {
+		return err
+	}
+	for canceledIter.Next() {
+		select {
+		case canceled <- canceledIter.Event:
+		case <-ctx.Done():
+		}
+	}
+	err = canceledIter.Error()
+	_ = canceledIter.Close()
+	if err != nil {
+		return err
+	}
+
+	fulfilledIter, err := n.core().FilterFulfilled(opts, nil)
+	if err != nil {
+		return err
+	}
+	for fulfilledIter.Next() {
+		select {
+		case fulfilled <- fulfilledIter.Event:
+		case <-ctx.Done():
+		}
+	}
+	err = fulfilledIter.Error()
+	_ = fulfilledIter.Close()
+	return err
+}
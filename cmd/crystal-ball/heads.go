@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+// HeadPollInterval defines how often the latest block is requested by the head tracker
+const HeadPollInterval = 250 * time.Millisecond
+
+// headTracker follows time of the latest block, so that jobs don't have to poll the endpoint on their own
+type headTracker struct {
+	mutex sync.Mutex
+	time  uint64
+	// updated is closed and replaced every time the head changes
+	updated chan struct{}
+}
+
+func newHeadTracker() *headTracker {
+	return &headTracker{updated: make(chan struct{})}
+}
+
+// run polls the latest block until ctx is canceled
+func (h *headTracker) run(ctx context.Context, latest func(ctx context.Context) (uint64, error)) {
+	ticker := time.NewTicker(HeadPollInterval)
+	defer ticker.Stop()
+	for {
+		t, err := latest(ctx)
+		if err != nil {
+			log.Debug().Err(err).Msg("could not get latest block time")
+		} else {
+			h.set(t)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *headTracker) set(t uint64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if t <= h.time {
+		return
+	}
+	h.time = t
+	close(h.updated)
+	h.updated = make(chan struct{})
+}
+
+func (h *headTracker) get() (uint64, <-chan struct{}) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.time, h.updated
+}
+
+// waitFor blocks until the latest block has timestamp of at least t
+func (h *headTracker) waitFor(ctx context.Context, t time.Time) error {
+	for {
+		head, updated := h.get()
+		if head >= uint64(t.Unix()) {
+			return nil
+		}
+		select {
+		case <-updated:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
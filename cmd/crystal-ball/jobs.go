@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/orakurudata/crystal-ball/cmd/crystal-ball/monitoring"
+	"github.com/orakurudata/crystal-ball/database"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// job is a request that is being executed by the node
+type job struct {
+	executionTime time.Time
+	cancel        context.CancelFunc
+	// status is set when the job was aborted, and it is recorded in the journal when the job exits
+	status database.RequestStatus
+}
+
+// isActive reports whether the request is being executed
+func (n *Node) isActive(requestID [32]byte) bool {
+	n.ActiveRequestsMutex.Lock()
+	defer n.ActiveRequestsMutex.Unlock()
+	_, ok := n.ActiveRequests[requestID]
+	return ok
+}
+
+// startJob registers a new job and returns the context it has to be executed with
+func (n *Node) startJob(requestID [32]byte, executionTime time.Time) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	n.ActiveRequestsMutex.Lock()
+	n.ActiveRequests[requestID] = &job{executionTime: executionTime, cancel: cancel}
+	n.ActiveRequestsMutex.Unlock()
+	n.jobs.Add(1)
+	return ctx
+}
+
+// finishJob removes the job, and records the reason it was aborted with
+func (n *Node) finishJob(requestID [32]byte) {
+	n.ActiveRequestsMutex.Lock()
+	j, ok := n.ActiveRequests[requestID]
+	delete(n.ActiveRequests, requestID)
+	n.ActiveRequestsMutex.Unlock()
+	if !ok {
+		return
+	}
+	defer n.jobs.Done()
+	j.cancel()
+	if j.status != "" && n.closeRequest(requestID, j.status) {
+		log.Info().Str("id", hexutil.Encode(requestID[:])).Str("status", string(j.status)).Msg("job was aborted")
+		monitoring.AbortedJobsCounter.Inc()
+	}
+}
+
+// abortRequest stops execution of the request, because it was closed on-chain
+func (n *Node) abortRequest(requestID [32]byte, status database.RequestStatus) {
+	n.ActiveRequestsMutex.Lock()
+	j, ok := n.ActiveRequests[requestID]
+	if ok {
+		j.status = status
+		j.cancel()
+	}
+	n.ActiveRequestsMutex.Unlock()
+	if !ok {
+		// Request is not executed right now, but the journal might still wait for it
+		n.closeRequest(requestID, status)
+	}
+}
+
+// Drain waits for jobs to finish after the request executor was stopped. Jobs that are not going to be executed
+// before timeout are canceled right away, they stay in the journal and are resumed when the node is started again.
+// When timeout is reached, remaining jobs are canceled as well.
+func (n *Node) Drain(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	if n.executorDone != nil {
+		timer := time.NewTimer(timeout)
+		select {
+		case <-n.executorDone:
+		case <-timer.C:
+			log.Warn().Msg("request executor did not stop in time")
+		}
+		timer.Stop()
+	}
+
+	if n.halt != nil {
+		// Node is halted once draining is over, which also stops the head tracker
+		defer n.halt()
+	}
+
+	var draining, postponed int
+	n.ActiveRequestsMutex.Lock()
+	for _, j := range n.ActiveRequests {
+		if j.executionTime.Before(deadline) {
+			draining++
+			continue
+		}
+		postponed++
+		j.cancel()
+	}
+	n.ActiveRequestsMutex.Unlock()
+	log.Info().Int("draining", draining).Int("postponed", postponed).Dur("timeout", timeout).Msg("waiting for imminent jobs to finish")
+
+	done := make(chan struct{})
+	go func() {
+		n.jobs.Wait()
+		close(done)
+	}()
+	// Time spent waiting for the executor counts towards the timeout
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-done:
+		log.Info().Msg("all jobs finished")
+		return
+	case <-timer.C:
+	}
+
+	log.Warn().Msg("drain timeout reached, canceling remaining jobs")
+	n.ActiveRequestsMutex.Lock()
+	for _, j := range n.ActiveRequests {
+		j.cancel()
+	}
+	n.ActiveRequestsMutex.Unlock()
+	if n.halt != nil {
+		// Stop waiting for receipts, submitted requests are reconciled on the next start
+		n.halt()
+	}
+	<-done
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/orakurudata/crystal-ball/contracts"
+	"github.com/orakurudata/crystal-ball/database"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// JournalRetention defines how long finished requests are kept in the database
+const JournalRetention = 7 * 24 * time.Hour
+
+// journalRequest records a new request in the database. It returns the stored record and whether this
+// request should be executed, which is not the case for requests that were already submitted or finished.
+func (n *Node) journalRequest(ev *contracts.IOrakuruCoreRequested) (*database.Request, bool) {
+	id := hexutil.Encode(ev.RequestId[:])
+	rec, err := n.DB.GetRequest(ev.RequestId[:])
+	if err == nil {
+		if rec.Status == database.StatusSubmitted || rec.Status.Final() {
+			log.Trace().Str("id", id).Str("status", string(rec.Status)).Msg("request was already processed")
+			return rec, false
+		}
+		return rec, true
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Error().Err(err).Caller().Str("id", id).Msg("could not read request from the journal")
+	}
+	rec = &database.Request{
+		RequestID:          ev.RequestId[:],
+		DataSource:         ev.DataSource,
+		Selector:           ev.Selector,
+		ExecutionTimestamp: time.Unix(ev.ExecutionTimestamp.Int64(), 0),
+		AggrType:           ev.AggrType,
+		Precision:          ev.Precision,
+		Status:             database.StatusReceived,
+	}
+	err = n.DB.AddRequest(rec)
+	if err != nil {
+		log.Error().Err(err).Caller().Str("id", id).Msg("could not add request to the journal")
+	}
+	return rec, true
+}
+
+// closeRequest records that the request was closed on-chain. Submitted and finished requests are left as is,
+// because the outcome of our own transaction is more informative. It reports whether the status was changed.
+func (n *Node) closeRequest(requestID [32]byte, status database.RequestStatus) bool {
+	rec, err := n.DB.GetRequest(requestID[:])
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Error().Err(err).Caller().Str("id", hexutil.Encode(requestID[:])).Msg("could not read request from the journal")
+		}
+		return false
+	}
+	if rec.Status == database.StatusSubmitted || rec.Status.Final() {
+		return false
+	}
+	n.setRequestStatus(requestID, status)
+	return true
+}
+
+func (n *Node) setRequestStatus(requestID [32]byte, status database.RequestStatus) {
+	err := n.DB.SetRequestStatus(requestID[:], status)
+	if err != nil {
+		log.Error().Err(err).Caller().Str("id", hexutil.Encode(requestID[:])).Msg("could not update request status")
+	}
+}
+
+func (n *Node) setRequestResult(requestID [32]byte, result string) {
+	err := n.DB.SetRequestResult(requestID[:], result)
+	if err != nil {
+		log.Error().Err(err).Caller().Str("id", hexutil.Encode(requestID[:])).Msg("could not store request result")
+	}
+}
+
+func (n *Node) setRequestTransaction(requestID [32]byte, txHash string) {
+	err := n.DB.SetRequestTransaction(requestID[:], txHash)
+	if err != nil {
+		log.Error().Err(err).Caller().Str("id", hexutil.Encode(requestID[:])).Msg("could not store request transaction")
+	}
+}
+
+// reconcileJournal compares unfinished requests in the database with pending requests in the core contract.
+// Requests that are no longer pending will never be executed again, so they are closed. Outcome of submitted
+// requests is taken from receipts of their transactions.
+func (n *Node) reconcileJournal(ctx context.Context, pending [][32]byte) error {
+	requests, err := n.DB.GetUnfinishedRequests()
+	if err != nil {
+		return err
+	}
+	pendingSet := make(map[[32]byte]bool, len(pending))
+	for _, id := range pending {
+		pendingSet[id] = true
+	}
+	for _, rec := range requests {
+		var id [32]byte
+		copy(id[:], rec.RequestID)
+		if rec.Status == database.StatusSubmitted {
+			n.reconcileSubmission(ctx, id, rec, pendingSet[id])
+			continue
+		}
+		if pendingSet[id] {
+			log.Info().Str("id", hexutil.Encode(id[:])).Str("status", string(rec.Status)).Msg("resuming request from the journal")
+			continue
+		}
+		log.Warn().Str("id", hexutil.Encode(id[:])).Str("status", string(rec.Status)).Msg("request is no longer pending, it was dropped")
+		n.setRequestStatus(id, database.StatusExpired)
+	}
+	return n.DB.DeleteRequestsBefore(time.Now().Add(-JournalRetention))
+}
+
+// reconcileSubmission sets status of a submitted request by the receipt of its transaction. When the transaction
+// is not mined, a pending request is submitted again using the result from the journal.
+func (n *Node) reconcileSubmission(ctx context.Context, id [32]byte, rec *database.Request, pending bool) {
+	receipt, err := n.Client.TransactionReceipt(ctx, common.HexToHash(rec.TxHash))
+	switch {
+	case err == nil && receipt.Status == types.ReceiptStatusSuccessful:
+		n.setRequestStatus(id, database.StatusMined)
+	case err == nil:
+		n.setRequestStatus(id, database.StatusReverted)
+	case !errors.Is(err, ethereum.NotFound):
+		log.Warn().Err(err).Str("id", hexutil.Encode(id[:])).Str("tx", rec.TxHash).Msg("could not get receipt of submitted request")
+		if !pending {
+			n.setRequestStatus(id, database.StatusFulfilled)
+		}
+	case pending:
+		// Contract is checked for our response before the result is submitted again
+		log.Info().Str("id", hexutil.Encode(id[:])).Str("tx", rec.TxHash).Msg("submission was not mined, resuming request from the journal")
+		n.setRequestStatus(id, database.StatusExecuted)
+	default:
+		n.setRequestStatus(id, database.StatusDropped)
+	}
+}
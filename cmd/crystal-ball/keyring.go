@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/hex"
+	"github.com/orakurudata/crystal-ball/secrets"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// RetirementWarning defines how long before retirement of a key the node starts warning about it
+const RetirementWarning = 7 * 24 * time.Hour
+
+// keyring returns all secret keys of the node, secret_key goes first and never retires
+func (n *Node) keyring() secrets.Keyring {
+	n.keysOnce.Do(func() {
+		if len(n.Requests.SecretKey) == 32 {
+			key, err := secrets.NewKey(n.Requests.SecretKey, time.Time{})
+			if err == nil {
+				n.keys = append(n.keys, key)
+			}
+		}
+		for _, v := range n.Requests.SecretKeys {
+			key, err := secrets.NewKey(v.Key, v.RetireAt)
+			if err != nil {
+				log.Warn().Err(err).Msg("failed to derive secret key")
+				continue
+			}
+			n.keys = append(n.keys, key)
+		}
+	})
+	return n.keys
+}
+
+// logKeyring reports keys of the node, so that operators know which pubkeys are still accepted
+func (n *Node) logKeyring() {
+	now := time.Now()
+	keys := n.keyring()
+	if len(keys.Active(now)) == 0 {
+		log.Warn().Msg("node has no active secret keys, requests with secrets will fail")
+	}
+	for _, key := range keys {
+		l := log.Info()
+		if !key.RetireAt.IsZero() && key.RetireAt.Sub(now) < RetirementWarning {
+			l = log.Warn()
+		}
+		l = l.Str("key_id", hex.EncodeToString(key.ID)).Bool("retired", key.Retired(now))
+		if !key.RetireAt.IsZero() {
+			l = l.Time("retire_at", key.RetireAt)
+		}
+		l.Msg("secret key loaded")
+	}
+}
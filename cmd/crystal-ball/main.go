@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"github.com/orakurudata/crystal-ball/cmd/crystal-ball/monitoring"
 	"github.com/orakurudata/crystal-ball/configuration"
+	"github.com/orakurudata/crystal-ball/database"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"os"
@@ -10,6 +12,7 @@ import (
 	"path"
 	"strings"
 	"syscall"
+	"time"
 )
 
 func getenv(env, def string) string {
@@ -34,6 +37,11 @@ func main() {
 	loadLogLevel(getenv("CB_LOG_LEVEL", "info"))
 	prettyLogging := getenv("CB_PRETTY_LOG", "true")
 	prometheusHost := getenv("MONITORING_HOST", ":9000")
+	databaseURL := getenv("CB_DATABASE", path.Join(configDirectory, "crystal-ball.db"))
+	drainTimeout, err := time.ParseDuration(getenv("CB_DRAIN_TIMEOUT", "8s"))
+	if err != nil {
+		log.Fatal().Err(err).Caller().Msg("failed to parse drain timeout")
+	}
 	if prettyLogging == "true" {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
 	}
@@ -48,7 +56,7 @@ func main() {
 		log.Fatal().Err(err).Caller().Msg("failed to parse requests configuration")
 	}
 	_ = requestsFile.Close()
-	if len(requestsConfig.SecretKey) != 32 {
+	if requestsConfig.RawSecretKey != "" && len(requestsConfig.SecretKey) != 32 {
 		log.Warn().Msg("configuration file contains an invalid secret key")
 	}
 
@@ -62,11 +70,20 @@ func main() {
 	}
 	_ = web3File.Close()
 
+	db, err := database.OpenConnection(databaseURL)
+	if err != nil {
+		log.Fatal().Err(err).Caller().Msg("failed to open database")
+	}
+	defer db.Close()
+
 	node := Node{
 		Requests: requestsConfig,
 		Web3:     web3Config,
+		DB:       db,
 	}
-	err = node.Start()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = node.Start(ctx)
 	if err != nil {
 		log.Error().Err(err).Caller().Msg("failed to start node")
 		return
@@ -75,4 +92,6 @@ func main() {
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 	<-sig
 	log.Info().Msg("received exit signal, stopping")
+	cancel()
+	node.Drain(drainTimeout)
 }
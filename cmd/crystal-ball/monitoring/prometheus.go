@@ -29,6 +29,41 @@ var (
 		Namespace: "crystal_ball",
 		Help:      "Amount of jobs that could not be executed",
 	})
+	AbortedJobsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name:      "aborted_jobs",
+		Namespace: "crystal_ball",
+		Help:      "Amount of jobs that were aborted, because their requests were canceled or fulfilled",
+	})
+	SkippedSubmissionsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name:      "skipped_submissions",
+		Namespace: "crystal_ball",
+		Help:      "Amount of results that were not submitted, because the submission would revert",
+	})
+	DisagreeingSamplesCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name:      "disagreeing_samples",
+		Namespace: "crystal_ball",
+		Help:      "Amount of jobs whose data source returned different values in its samples",
+	})
+	SubmittedTransactionsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name:      "submitted_transactions",
+		Namespace: "crystal_ball",
+		Help:      "Amount of result transactions that were sent to the network",
+	})
+	MinedTransactionsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name:      "mined_transactions",
+		Namespace: "crystal_ball",
+		Help:      "Amount of result transactions that were mined successfully",
+	})
+	RevertedTransactionsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name:      "reverted_transactions",
+		Namespace: "crystal_ball",
+		Help:      "Amount of result transactions that were mined, but reverted",
+	})
+	DroppedTransactionsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name:      "dropped_transactions",
+		Namespace: "crystal_ball",
+		Help:      "Amount of result transactions that were never mined",
+	})
 )
 
 func StartMonitoring(host string) {
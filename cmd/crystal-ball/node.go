@@ -1,58 +1,86 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/antchfx/xmlquery"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/event"
-	"github.com/oliveagle/jsonpath"
 	"github.com/orakurudata/crystal-ball/cmd/crystal-ball/monitoring"
 	"github.com/orakurudata/crystal-ball/configuration"
 	"github.com/orakurudata/crystal-ball/contracts"
+	"github.com/orakurudata/crystal-ball/database"
+	"github.com/orakurudata/crystal-ball/endpoints"
+	"github.com/orakurudata/crystal-ball/executor/selector"
 	"github.com/orakurudata/crystal-ball/secrets"
+	"github.com/orakurudata/crystal-ball/txmanager"
 	"github.com/rs/zerolog/log"
 	"github.com/shopspring/decimal"
 	"io"
 	"math/big"
 	"net/http"
-	"regexp"
-	"strconv"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 )
 
-var (
-	MainnetID = big.NewInt(56)
-	TestnetID = big.NewInt(97)
-)
-
 type Node struct {
 	Requests *configuration.Requests
 	Web3     *configuration.Web3
+	DB       *database.Conn
 
 	ChainID     *big.Int
+	Chain       *configuration.Chain
 	CoreAddress common.Address
-	Client      *ethclient.Client
+	Client      *endpoints.Pool
 	Core        *contracts.IOrakuruCore
 	Registry    *contracts.IAddressRegistry
 	Staking     *contracts.IStaking
+	Transactor  *txmanager.Manager
 
-	FulfillmentMutex *sync.Mutex
-
-	ActiveRequests      map[[32]byte]bool
+	ActiveRequests      map[[32]byte]*job
 	ActiveRequestsMutex *sync.Mutex
+
+	jobs sync.WaitGroup
+	// executorDone is closed when request executor exits
+	executorDone chan struct{}
+	// halted is canceled when draining is over, and the node stops waiting for submitted transactions and new heads
+	halted context.Context
+	halt   context.CancelFunc
+
+	// contractsMutex guards contract bindings, which change when the core is upgraded
+	contractsMutex sync.RWMutex
+	// coreChanged receives a value when the core address changes
+	coreChanged chan struct{}
+	heads       *headTracker
+
+	http     *http.Client
+	httpOnce sync.Once
+
+	keys     secrets.Keyring
+	keysOnce sync.Once
+
+	checkpointMutex sync.Mutex
+	checkpointBlock uint64
+	// backfilling is set while missed events are scanned, checkpoint can't be advanced until the scan is finished
+	backfilling bool
+	// pollNext is the first block that wasn't polled for events yet
+	pollNext uint64
 }
 
+const (
+	// SubmitAttempts defines how many times node tries to send the result before giving up
+	SubmitAttempts   = 3
+	SubmitRetryDelay = 5 * time.Second
+	// MaxResponseSize limits size of data source responses, so that selectors don't run on huge inputs
+	MaxResponseSize = 4 << 20
+)
+
 const (
 	AggrTypeMostFrequent = iota
 	AggrTypeMedian
@@ -60,175 +88,209 @@ const (
 )
 
 var (
-	JSONPathHotfix = regexp.MustCompile("(?U)\\[\"(.+)\"]")
-	SecretRegexp   = regexp.MustCompile(`(?U)\$\$(?P<key>.+):(?P<data>.+)\$\$`)
+	ErrResponseTooLarge = errors.New("data source response is too large")
+	ErrUnboundSecret    = errors.New("secret is not bound to a host")
+)
+
+var (
+	SecretRegexp = secrets.BlobRegexp
 )
 
-func (n *Node) Start() error {
+// Start connects to the network and runs the node until ctx is canceled
+func (n *Node) Start(ctx context.Context) error {
 	address := crypto.PubkeyToAddress(n.Web3.PrivateKey.PublicKey)
 	log.Info().Str("wallet", address.String()).Msg("crystal-ball is starting")
-	c, err := ethclient.Dial(n.Web3.URL)
+	n.logKeyring()
+	c, err := endpoints.Dial(ctx, n.Web3.URLs)
 	if err != nil {
 		return err
 	}
-	chainID, err := c.ChainID(context.Background())
+	go c.Run(ctx)
+	chainID, err := c.ChainID(ctx)
 	if err != nil {
 		return err
 	}
-	switch {
-	case chainID.Cmp(MainnetID) == 0:
-		log.Info().Msg("mainnet endpoint detected")
-	case chainID.Cmp(TestnetID) == 0:
-		log.Info().Msg("testnet endpoint detected")
-	default:
-		log.Warn().Msg("endpoint network is unknown")
+	n.Chain, err = n.Web3.ResolveChain(chainID)
+	if err != nil {
+		return err
+	}
+	if n.Chain.Name == configuration.UnknownChain.Name {
+		log.Warn().Uint64("chain_id", n.Chain.ChainID).Msg("endpoint network is unknown, using default chain profile")
+	} else {
+		log.Info().Str("chain", n.Chain.Name).Uint64("chain_id", n.Chain.ChainID).Msg("endpoint network detected")
 	}
 	n.ChainID = chainID
 	n.Client = c
-	n.CoreAddress = common.HexToAddress(n.Web3.OrakuruCore)
-	n.Core, err = contracts.NewIOrakuruCore(n.CoreAddress, n.Client)
-	n.FulfillmentMutex = &sync.Mutex{}
+	n.Transactor = txmanager.NewManager(c, n.Web3.PrivateKey, chainID, n.Web3.Gas)
 	n.ActiveRequestsMutex = &sync.Mutex{}
-	n.ActiveRequests = make(map[[32]byte]bool)
-	if err != nil {
-		return err
-	}
-	registryAddr, err := n.Core.AddressRegistry(nil)
+	n.ActiveRequests = make(map[[32]byte]*job)
+	n.coreChanged = make(chan struct{}, 1)
+	n.heads = newHeadTracker()
+	err = n.resolveContracts(ctx)
 	if err != nil {
 		return err
 	}
-	n.Registry, err = contracts.NewIAddressRegistry(registryAddr, c)
+	log.Info().Str("core", n.CoreAddress.String()).Msg("using core contract")
+	oracle, err := n.staking().IsRegisteredOracle(nil, crypto.PubkeyToAddress(n.Web3.PrivateKey.PublicKey))
 	if err != nil {
 		return err
 	}
-	stakingAddr, err := n.Registry.GetStakingAddr(nil)
-	if err != nil {
-		return err
+	if !oracle {
+		log.Error().Caller().Msg("current wallet is not a registered oracle")
 	}
-	n.Staking, err = contracts.NewIStaking(stakingAddr, c)
+	pending, err := n.core().GetPendingRequests(nil)
 	if err != nil {
 		return err
 	}
-	oracle, err := n.Staking.IsRegisteredOracle(nil, crypto.PubkeyToAddress(n.Web3.PrivateKey.PublicKey))
+	err = n.reconcileJournal(ctx, pending)
 	if err != nil {
 		return err
 	}
-	if !oracle {
-		log.Error().Caller().Msg("current wallet is not a registered oracle")
-	}
-	n.Run()
+	n.Run(ctx)
 	return nil
 }
 
-func (n *Node) Run() {
-	go n.RunRequestExecutor()
-	go n.updateMonitoringBalance()
+func (n *Node) Run(ctx context.Context) {
+	n.halted, n.halt = context.WithCancel(context.Background())
+	n.executorDone = make(chan struct{})
+	go func() {
+		defer close(n.executorDone)
+		n.RunRequestExecutor(ctx)
+	}()
+	go n.updateMonitoringBalance(ctx)
+	go n.watchRegistry(ctx)
+	// Jobs that are drained after ctx is canceled still wait for their execution time to be reached on-chain
+	go n.heads.run(n.halted, func(ctx context.Context) (uint64, error) {
+		return getLastBlockTime(ctx, n.Client)
+	})
 }
 
+// UnwrapSecrets decrypts secrets in url. Secrets bound to a host are only decrypted if url points to that host
+// after the secrets are substituted
 func (n *Node) UnwrapSecrets(url string) (string, error) {
-	out := SecretRegexp.FindAllStringSubmatch(url, -1)
+	out, bindings, err := n.unwrapSecrets(url, identity)
+	if err != nil {
+		return url, err
+	}
+	err = n.checkBindings(bindings, urlHost(out))
+	if err != nil {
+		return url, err
+	}
+	return out, nil
+}
+
+// unwrapSecrets decrypts secrets in text and replaces them with their encoded values.
+// It returns hosts the secrets are bound to, which have to be checked with checkBindings before text is sent.
+func (n *Node) unwrapSecrets(text string, encode func(string) string) (string, []string, error) {
+	var bindings []string
+	out := SecretRegexp.FindAllStringSubmatch(text, -1)
 	for _, match := range out {
 		source := match[0]
-		key, err := base64.StdEncoding.DecodeString(match[SecretRegexp.SubexpIndex("key")])
+		value, binding, err := n.keyring().Open(source, time.Now())
 		if err != nil {
-			return url, err
+			return text, nil, err
 		}
-		ciphertext, err := base64.StdEncoding.DecodeString(match[SecretRegexp.SubexpIndex("data")])
-		if err != nil {
-			return url, err
+		bindings = append(bindings, binding)
+		text = strings.Replace(text, source, encode(value), 1)
+	}
+	return text, bindings, nil
+}
+
+// checkBindings makes sure that secrets may be sent to host. Unbound secrets are accepted unless
+// the configuration requires bound ones
+func (n *Node) checkBindings(bindings []string, host string) error {
+	for _, binding := range bindings {
+		if binding == "" {
+			if n.Requests.RequireBoundSecrets {
+				return ErrUnboundSecret
+			}
+			continue
 		}
-		publicKey := secrets.PublicKey(key)
-		seed := secrets.Seed(n.Requests.SecretKey)
-		value, err := secrets.Decrypt(seed, publicKey, ciphertext)
-		if err != nil {
-			return url, err
+		if !secrets.MatchHost(binding, host) {
+			log.Warn().Str("host", host).Str("binding", binding).Msg("refusing to send secret to another host")
+			return secrets.ErrHostMismatch
 		}
-		url = strings.Replace(url, source, value, 1)
 	}
-	return url, nil
+	return nil
+}
+
+func identity(value string) string {
+	return value
 }
 
-func (n *Node) executeRequest(url, query string) (string, error) {
-	url, err := n.UnwrapSecrets(url)
+// urlHost returns host the request to rawURL is sent to
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		log.Warn().Caller().Err(err).Msg("failed to unwrap secrets in URL")
+		return ""
 	}
-	r, err := http.NewRequest("GET", url, nil)
+	return u.Hostname()
+}
+
+func (n *Node) executeRequest(ctx context.Context, source *DataSource, query string) (string, error) {
+	source, err := n.unwrapDataSourceSecrets(source)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("failed to unwrap secrets in data source: %w", err)
 	}
-	if query[0] == '$' {
-		r.Header.Set("Accept", "application/json")
-	} else if query[0] == '/' {
-		r.Header.Set("Accept", "application/xml")
+	do := func(c *http.Client) (*http.Response, error) {
+		r, err := source.NewRequest(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if accept := selector.Accept(query); accept != "" && r.Header.Get("Accept") == "" {
+			r.Header.Set("Accept", accept)
+		}
+		return c.Do(r)
 	}
-	c := &http.Client{}
-	c.Timeout = n.Requests.Timeout
-	resp, err := c.Do(r)
+	c := n.httpClient()
+	resp, err := do(c)
 	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 		time.Sleep(200 * time.Millisecond)
-		resp, err = c.Do(r)
+		resp, err = do(c)
 		if err != nil {
 			return "", err
 		}
 	}
 	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
 		return "", fmt.Errorf("request execution failed, http status %d", resp.StatusCode)
 	}
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize+1))
+	_ = resp.Body.Close()
 	if err != nil {
 		return "", err
 	}
-	_ = resp.Body.Close()
-
-	if query[0] == '$' {
-		query = JSONPathHotfix.ReplaceAllString(query, ".$1")
-		q, err := jsonpath.Compile(query)
-		if err != nil {
-			return "", err
-		}
-		var data interface{}
-		err = json.Unmarshal(body, &data)
-		if err != nil {
-			return "", err
-		}
-		var resp interface{}
-		resp, err = q.Lookup(data)
-		if err != nil {
-			return "", err
-		}
-		switch r := resp.(type) {
-		case string:
-			return r, nil
-		case float64:
-			return strconv.FormatFloat(r, 'f', -1, 64), nil
-		default:
-			return "", errors.New("invalid jsonpath provided")
-		}
-	} else if query[0] == '/' {
-		doc, err := xmlquery.Parse(bytes.NewReader(body))
-		if err != nil {
-			return "", err
-		}
-		nodes, err := xmlquery.QueryAll(doc, query)
-		if err != nil {
-			return "", err
-		}
-		if len(nodes) != 1 {
-			return "", errors.New("invalid xpath provided")
-		}
-		return nodes[0].Data, nil
+	if len(body) > MaxResponseSize {
+		return "", ErrResponseTooLarge
 	}
-	return "", errors.New("unknown query provided")
+
+	return selector.Execute(body, query)
 }
 
-func sleepUntil(t time.Time) {
-	time.Sleep(time.Until(t))
+// sleepUntil waits until t, or returns an error if ctx is canceled earlier
+func sleepUntil(ctx context.Context, t time.Time) error {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (n *Node) updateMonitoringBalance() {
-	ticker := time.Tick(5 * time.Minute)
-	for range ticker {
+func (n *Node) updateMonitoringBalance(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
 		balance, err := n.Client.BalanceAt(context.Background(), crypto.PubkeyToAddress(n.Web3.PrivateKey.PublicKey), nil)
 		if err != nil {
 			log.Error().Err(err).Msg("could not update balance")
@@ -241,19 +303,15 @@ func (n *Node) updateMonitoringBalance() {
 }
 
 func validateNumber(number *string) bool {
-	_, err := strconv.ParseFloat(*number, 64)
-	if err == nil {
-		return true
-	}
-	*number = strings.Replace(*number, ",", ".", 1)
-	_, err = strconv.ParseFloat(*number, 64)
-	return err == nil
+	normalized, ok := selector.NormalizeNumber(*number)
+	*number = normalized
+	return ok
 }
 
 // Gets the last block time
-func getLastBlockTime(client *ethclient.Client) (uint64, error) {
+func getLastBlockTime(ctx context.Context, client *endpoints.Pool) (uint64, error) {
 	// Get latest block header
-	header, err := client.HeaderByNumber(context.Background(), nil)
+	header, err := client.HeaderByNumber(ctx, nil)
 
 	// Return zero if failed
 	if err != nil {
@@ -264,128 +322,203 @@ func getLastBlockTime(client *ethclient.Client) (uint64, error) {
 	return header.Time, nil
 }
 
-func (n *Node) execute(event *contracts.IOrakuruCoreRequested, executionTime time.Time) {
+// checkSubmission looks for reasons why submission of the result would revert. It returns the status
+// the request should be closed with, or an empty status if the result should be submitted.
+func (n *Node) checkSubmission(ctx context.Context, core *contracts.IOrakuruCore, requestID [32]byte) (database.RequestStatus, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	req, err := core.GetRequest(opts, requestID)
+	if err != nil {
+		return "", err
+	}
+	if req.IsFulfilled {
+		return database.StatusFulfilled, nil
+	}
+	responses, err := core.GetResponses(opts, requestID)
+	if err != nil {
+		return "", err
+	}
+	threshold, err := n.staking().GetThresholdNum(opts)
+	if err != nil {
+		return "", err
+	}
+	return submissionStatus(responses, threshold, n.Transactor.Address()), nil
+}
+
+// submissionStatus returns StatusResponded if oracle has already submitted a response,
+// StatusFulfilled if there are enough responses, and an empty status otherwise
+func submissionStatus(responses []contracts.IOrakuruCoreResponse, threshold *big.Int, oracle common.Address) database.RequestStatus {
+	for _, r := range responses {
+		if r.SubmittedBy == oracle {
+			return database.StatusResponded
+		}
+	}
+	if threshold != nil && threshold.Sign() > 0 && big.NewInt(int64(len(responses))).Cmp(threshold) >= 0 {
+		return database.StatusFulfilled
+	}
+	return ""
+}
+
+// execute runs the request and submits its result. The job is aborted when ctx is canceled,
+// in which case its status is recorded by finishJob.
+func (n *Node) execute(ctx context.Context, event *contracts.IOrakuruCoreRequested, rec *database.Request, executionTime time.Time) {
 	monitoring.QueueGauge.Inc()
 	defer func() {
 		monitoring.QueueGauge.Dec()
 		monitoring.ExecutedJobsCounter.Inc()
-
-		n.ActiveRequestsMutex.Lock()
-		delete(n.ActiveRequests, event.RequestId)
-		n.ActiveRequestsMutex.Unlock()
+		n.finishJob(event.RequestId)
 	}()
+	// Result is submitted to the core that emitted the request, even if the core is upgraded meanwhile
+	core := n.core()
 
 	// Perform validation immediately upon receiving request
-	allowed, err := n.Requests.Filter.ValidateURL(event.DataSource)
+	source, err := ParseDataSource(event.DataSource)
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("data source decoding failed, possibly an invalid request - ignoring")
+		monitoring.FailedJobsCounter.Inc()
+		n.setRequestStatus(event.RequestId, database.StatusFailed)
+		return
+	}
+	allowed, err := n.Requests.Filter.ValidateURL(source.URL)
 	if err != nil {
 		log.Warn().Err(err).Caller().Msg("url validation failed, possibly an invalid request - ignoring")
 		monitoring.FailedJobsCounter.Inc()
+		n.setRequestStatus(event.RequestId, database.StatusFailed)
 		return
 	}
 	if !allowed {
 		log.Warn().Msg("request violates security policy - ignoring")
+		n.setRequestStatus(event.RequestId, database.StatusIgnored)
 		return
 	}
 
-	// Sleep until execution time
-	sleepUntil(executionTime)
-
-	// Perform execution like normal
-	log.Trace().Str("id", hexutil.Encode(event.RequestId[:])).Msg("executing request")
-
-	resp, err := n.executeRequest(event.DataSource, event.Selector)
-	if err != nil {
-		log.Warn().Err(err).Caller().Msg("request execution failed")
-		monitoring.FailedJobsCounter.Inc()
-		return
-	}
-	log.Trace().Str("id", hexutil.Encode(event.RequestId[:])).Str("result", resp).Msg("request executed successfully. waiting to submit.")
-	if event.AggrType == AggrTypeAverage || event.AggrType == AggrTypeMedian {
-		if !validateNumber(&resp) {
-			log.Warn().
-				Str("id", hexutil.Encode(event.RequestId[:])).
-				Str("result", resp).
-				Msg("wanted a number, got a string")
-			monitoring.FailedJobsCounter.Inc()
+	var resp string
+	if rec.Status == database.StatusExecuted {
+		// Result was fetched by the previous incarnation of the node, there's no need to query the source again
+		resp = rec.Result
+		log.Trace().Str("id", hexutil.Encode(event.RequestId[:])).Msg("using result from the journal")
+	} else {
+		resp, err = n.fetch(ctx, event, source, executionTime)
+		if ctx.Err() != nil {
 			return
 		}
-	}
-
-	// wait for current last block to reach execution timestamp or greater
-	for {
-		// Get last block time
-		lastBlockTime, err := getLastBlockTime(n.Client)
-		// If its an error, just sleep 3 seconds like before
 		if err != nil {
-			log.Warn().Err(err).Msg("could not get latest block time")
-			time.Sleep(3 * time.Second)
-			break
-		}
-
-		// If last block's time implies valid submission on next block, submit
-		if lastBlockTime >= uint64(executionTime.Unix()) {
-			break
+			log.Warn().Err(err).Caller().Msg("request execution failed")
+			monitoring.FailedJobsCounter.Inc()
+			n.setRequestStatus(event.RequestId, database.StatusFailed)
+			return
 		}
-
-		// Otherwise, sleep a little
-		time.Sleep(100 * time.Millisecond)
 	}
-
-	k, err := bind.NewKeyedTransactorWithChainID(n.Web3.PrivateKey, n.ChainID)
+	log.Trace().Str("id", hexutil.Encode(event.RequestId[:])).Str("result", resp).Msg("request executed successfully. waiting to submit.")
+	normalized, err := normalizeResult(resp, event.AggrType, event.Precision)
 	if err != nil {
-		log.Error().Err(err).Caller().Msg("cannot create keyed transactor")
+		log.Warn().Err(err).
+			Str("id", hexutil.Encode(event.RequestId[:])).
+			Str("result", resp).
+			Msg("result can't be submitted")
 		monitoring.FailedJobsCounter.Inc()
+		n.setRequestStatus(event.RequestId, database.StatusFailed)
+		return
+	}
+	resp = normalized
+	n.setRequestResult(event.RequestId, resp)
+
+	// Wait for the last block to reach execution timestamp, so that submission is valid in the next block.
+	// If head tracker falls behind, result is submitted anyway a couple of blocks after execution timestamp.
+	waitCtx, cancel := context.WithDeadline(ctx, executionTime.Add(2*n.Chain.BlockTime))
+	err = n.heads.waitFor(waitCtx, executionTime)
+	cancel()
+	if ctx.Err() != nil {
 		return
 	}
-	n.FulfillmentMutex.Lock()
-	tx, err := n.Core.SubmitResult(k, event.RequestId, resp)
-	n.FulfillmentMutex.Unlock()
 	if err != nil {
-		log.Error().Err(err).Caller().Msg("cannot submit transaction to the network")
-		log.Warn().Msg("waiting 5 seconds before trying to submit the result again")
-		time.Sleep(5 * time.Second)
-		n.FulfillmentMutex.Lock()
-		tx, err = n.Core.SubmitResult(k, event.RequestId, resp)
-		n.FulfillmentMutex.Unlock()
-		if err != nil {
+		log.Warn().Str("id", hexutil.Encode(event.RequestId[:])).Msg("latest block is unknown, submitting without waiting for it")
+	}
+
+	// Submission reverts if we have already responded, or other oracles have already reached the threshold
+	status, err := n.checkSubmission(ctx, core, event.RequestId)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("id", hexutil.Encode(event.RequestId[:])).Msg("could not check request responses, submitting anyway")
+	}
+	if status != "" {
+		log.Info().Str("id", hexutil.Encode(event.RequestId[:])).Str("status", string(status)).Msg("skipping submission of the result")
+		monitoring.SkippedSubmissionsCounter.Inc()
+		n.setRequestStatus(event.RequestId, status)
+		return
+	}
+
+	deadline := executionTime.Add(n.Chain.ExecutionWindow)
+	var tx *txmanager.Transaction
+	for attempt := 1; ; attempt++ {
+		tx, err = n.Transactor.Send(ctx, deadline, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return core.SubmitResult(opts, event.RequestId, resp)
+		})
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error().Err(err).Caller().Int("attempt", attempt).Msg("cannot submit transaction to the network")
+		if attempt == SubmitAttempts || time.Now().Add(SubmitRetryDelay).After(deadline) {
 			monitoring.FailedJobsCounter.Inc()
+			n.setRequestStatus(event.RequestId, database.StatusFailed)
+			return
+		}
+		log.Warn().Dur("delay", SubmitRetryDelay).Msg("waiting before trying to submit the result again")
+		if sleepUntil(ctx, time.Now().Add(SubmitRetryDelay)) != nil {
 			return
 		}
 	}
-	log.Info().Str("id", hexutil.Encode(event.RequestId[:])).Str("tx", tx.Hash().String()).Msg("request fulfilled")
-	//sleepUntil(fulfillmentTime)
-	// TODO: call fulfill request
+	monitoring.SubmittedTransactionsCounter.Inc()
+	n.setRequestTransaction(event.RequestId, tx.Hash().String())
+	log.Debug().Str("id", hexutil.Encode(event.RequestId[:])).Str("tx", tx.Hash().String()).Msg("result submitted, waiting for the transaction to be mined")
+	// Transaction is already sent, so its outcome is awaited even if the job is aborted or postponed
+	n.awaitSubmission(event.RequestId, tx)
 }
 
-func (n *Node) collectEvents(startBlock int64) ([]*contracts.IOrakuruCoreRequested, error) {
-	num, err := n.Client.BlockNumber(context.Background())
+// awaitSubmission waits until the result transaction is mined and records its outcome
+func (n *Node) awaitSubmission(requestID [32]byte, tx *txmanager.Transaction) {
+	id := hexutil.Encode(requestID[:])
+	receipt, err := tx.Wait(n.halted)
+	if n.halted.Err() != nil {
+		log.Warn().Str("id", id).Str("tx", tx.Hash().String()).Msg("node is stopping, result transaction outcome is unknown")
+		return
+	}
 	if err != nil {
-		return nil, err
+		log.Warn().Err(err).Str("id", id).Str("tx", tx.Hash().String()).Msg("result transaction was not mined")
+		monitoring.DroppedTransactionsCounter.Inc()
+		monitoring.FailedJobsCounter.Inc()
+		n.setRequestStatus(requestID, database.StatusDropped)
+		return
+	}
+	mined := tx.Mined()
+	if mined.Hash() != tx.Hashes()[0] {
+		// Transaction was replaced, journal should point to the version that was actually mined
+		n.setRequestTransaction(requestID, mined.Hash().String())
 	}
-	var out []*contracts.IOrakuruCoreRequested
-	for i := startBlock; uint64(i) <= num; i += 4001 {
-		end := uint64(i + 4000)
-		iter, err := n.Core.FilterRequested(&bind.FilterOpts{
-			Start: uint64(i),
-			End:   &end,
-		}, nil, nil)
+	if receipt.Status == types.ReceiptStatusFailed {
+		reason, err := n.Transactor.RevertReason(n.halted, mined, receipt)
 		if err != nil {
-			return nil, err
-		}
-		for iter.Next() {
-			out = append(out, iter.Event)
-		}
-		num, err = n.Client.BlockNumber(context.Background())
-		if err != nil {
-			return nil, err
+			log.Warn().Err(err).Str("tx", mined.Hash().String()).Msg("could not get revert reason")
 		}
+		log.Warn().Str("id", id).Str("tx", mined.Hash().String()).Uint64("block", receipt.BlockNumber.Uint64()).
+			Str("reason", reason).Msg("result transaction reverted")
+		monitoring.RevertedTransactionsCounter.Inc()
+		monitoring.FailedJobsCounter.Inc()
+		n.setRequestStatus(requestID, database.StatusReverted)
+		return
 	}
-	return out, nil
+	log.Info().Str("id", id).Str("tx", mined.Hash().String()).Uint64("block", receipt.BlockNumber.Uint64()).Msg("request fulfilled")
+	monitoring.MinedTransactionsCounter.Inc()
+	n.setRequestStatus(requestID, database.StatusMined)
 }
 
-func (n *Node) pushEvents(events [][32]byte, out chan<- *contracts.IOrakuruCoreRequested) {
+func (n *Node) pushEvents(ctx context.Context, events [][32]byte, out chan<- *contracts.IOrakuruCoreRequested) {
 	for _, event := range events {
-		req, err := n.Core.GetRequest(nil, event)
+		req, err := n.core().GetRequest(&bind.CallOpts{Context: ctx}, event)
 		if err != nil {
 			log.Error().Err(err).Caller().Msg("cannot retrieve event from contract")
 			return
@@ -394,82 +527,145 @@ func (n *Node) pushEvents(events [][32]byte, out chan<- *contracts.IOrakuruCoreR
 			RequestId:          event,
 			DataSource:         req.DataSource,
 			Selector:           req.Selector,
+			CallbackAddr:       req.CallbackAddr,
 			ExecutionTimestamp: req.ExecutionTimestamp,
 			AggrType:           req.AggrType,
+			Precision:          req.Precision,
 		}
 	}
 	log.Trace().Msg("past events were reloaded")
 }
 
-func (n *Node) RunRequestExecutor() {
+// RunRequestExecutor subscribes for requests and starts jobs until ctx is canceled
+func (n *Node) RunRequestExecutor(ctx context.Context) {
 	backoff := 5 * time.Second
-	for {
+	for ctx.Err() == nil {
 		log.Trace().Msg("reloading past events")
-		requests, err := n.Core.GetPendingRequests(nil)
+		requests, err := n.core().GetPendingRequests(&bind.CallOpts{Context: ctx})
 		if err != nil {
 			log.Error().Err(err).Caller().Msg("cannot get pending requests")
 			log.Warn().Dur("backoff", backoff).Msg("waiting before trying again")
-			time.Sleep(backoff)
+			_ = sleepUntil(ctx, time.Now().Add(backoff))
 			backoff += 5 * time.Second
 			continue
 		}
 
 		sink := make(chan *contracts.IOrakuruCoreRequested, len(requests)+100)
-		go n.pushEvents(requests, sink)
-
-		// TODO: maybe we should unsubscribe when node exits
-		sub, err := n.Core.WatchRequested(nil, sink, nil, nil)
+		canceled := make(chan *contracts.IOrakuruCoreCanceled, 100)
+		fulfilled := make(chan *contracts.IOrakuruCoreFulfilled, 100)
+		go n.pushEvents(ctx, requests, sink)
+		// Backfill is retried until it succeeds or the sink is replaced on resubscription
+		backfillCtx, stopBackfill := context.WithCancel(ctx)
+		go n.backfill(backfillCtx, sink)
+
+		sub, err := n.subscribe(sink, canceled, fulfilled)
 		if err != nil {
+			stopBackfill()
 			log.Error().Err(err).Caller().Msg("could not subscribe for new events")
 			log.Warn().Dur("backoff", backoff).Msg("waiting before trying again")
-			time.Sleep(backoff)
+			_ = sleepUntil(ctx, time.Now().Add(backoff))
 			backoff += 5 * time.Second
 			continue
 		}
 		backoff = 5 * time.Second
-		n.HandlerLoop(sub, sink)
+		n.HandlerLoop(ctx, sub, sink, canceled, fulfilled)
+		// Sink is not closed, because past events might still be pushed into it
 		sub.Unsubscribe()
-		close(sink)
+		stopBackfill()
+	}
+	log.Info().Msg("request executor stopped")
+}
+
+// subscribe watches for new requests, and for requests that were closed before our node finished them
+func (n *Node) subscribe(sink chan<- *contracts.IOrakuruCoreRequested, canceled chan<- *contracts.IOrakuruCoreCanceled,
+	fulfilled chan<- *contracts.IOrakuruCoreFulfilled) (event.Subscription, error) {
+	if n.eventSource() == configuration.EventSourcePoll {
+		return n.pollEvents(sink, canceled, fulfilled)
+	}
+	requestedSub, err := n.core().WatchRequested(nil, sink, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	canceledSub, err := n.core().WatchCanceled(nil, canceled, nil)
+	if err != nil {
+		requestedSub.Unsubscribe()
+		return nil, err
+	}
+	fulfilledSub, err := n.core().WatchFulfilled(nil, fulfilled, nil)
+	if err != nil {
+		requestedSub.Unsubscribe()
+		canceledSub.Unsubscribe()
+		return nil, err
 	}
+	return mergeSubscriptions(requestedSub, canceledSub, fulfilledSub), nil
 }
 
-func (n *Node) HandlerLoop(sub event.Subscription, sink chan *contracts.IOrakuruCoreRequested) {
+func (n *Node) HandlerLoop(ctx context.Context, sub event.Subscription, sink chan *contracts.IOrakuruCoreRequested,
+	canceled chan *contracts.IOrakuruCoreCanceled, fulfilled chan *contracts.IOrakuruCoreFulfilled) {
 	// As far as we I can tell, sometimes nodes drop long-term subscriptions without any notification.
 	// We'll resubscribe every 10 hours.
 	ticker := time.NewTicker(10 * time.Hour)
-	log.Info().Msg("subscribed for new requests")
+	defer ticker.Stop()
+	checkpointTicker := time.NewTicker(CheckpointInterval)
+	defer checkpointTicker.Stop()
+	// Checkpoint is moved to the head seen on the previous tick, so that its events had time to be delivered
+	var lastHead uint64
+	log.Info().Str("source", n.eventSource()).Msg("subscribed for new requests")
 	for {
 		select {
+		case <-ctx.Done():
+			return
+		case <-checkpointTicker.C:
+			if lastHead != 0 {
+				n.advanceCheckpoint(lastHead)
+			}
+			head, err := n.Client.BlockNumber(ctx)
+			if err != nil {
+				log.Warn().Err(err).Msg("could not get latest block number")
+				continue
+			}
+			lastHead = head
 		case ev := <-sink:
-			n.ActiveRequestsMutex.Lock()
-			_, ok := n.ActiveRequests[ev.RequestId]
-			n.ActiveRequestsMutex.Unlock()
-			if ok {
+			if n.isActive(ev.RequestId) {
 				continue
 			}
 
 			evt := ev
 			log.Trace().Str("id", hexutil.Encode(evt.RequestId[:])).Msg("new request received")
 			executionTime := time.Unix(evt.ExecutionTimestamp.Int64(), 0)
-			// FIXME: this time might change on mainnet
 			now := time.Now()
-			expire := executionTime.Add(1 * time.Minute)
+			expire := executionTime.Add(n.Chain.ExecutionWindow)
 			if !expire.After(now) {
 				log.Trace().Str("id", hexutil.Encode(evt.RequestId[:])).Time("now", now).Time("expire", expire).Msg("event is outdated")
 				// Event is expired, skip it
+				n.closeRequest(evt.RequestId, database.StatusExpired)
 				continue
 			}
 
-			n.ActiveRequestsMutex.Lock()
-			n.ActiveRequests[ev.RequestId] = true
-			n.ActiveRequestsMutex.Unlock()
-			go n.execute(evt, executionTime)
+			rec, ok := n.journalRequest(evt)
+			if !ok {
+				continue
+			}
+
+			if ctx.Err() != nil {
+				// Node is stopping, request is resumed from the journal on the next start
+				return
+			}
+			go n.execute(n.startJob(evt.RequestId, executionTime), evt, rec, executionTime)
+		case ev := <-canceled:
+			log.Debug().Str("id", hexutil.Encode(ev.RequestId[:])).Msg("request was canceled")
+			n.abortRequest(ev.RequestId, database.StatusCanceled)
+		case ev := <-fulfilled:
+			log.Debug().Str("id", hexutil.Encode(ev.RequestId[:])).Msg("request was fulfilled")
+			n.abortRequest(ev.RequestId, database.StatusFulfilled)
 		case err := <-sub.Err():
 			log.Error().Err(err).Caller().Msg("failed receiving events")
 			return
 		case <-ticker.C:
 			log.Info().Msg("performing re-subscription to keep connection durable")
-			ticker.Stop()
+			return
+		case <-n.coreChanged:
+			log.Info().Msg("performing re-subscription to the new core contract")
 			return
 		}
 	}
@@ -1,9 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/orakurudata/crystal-ball/cmd/crystal-ball/monitoring"
 	"github.com/orakurudata/crystal-ball/configuration"
+	"github.com/orakurudata/crystal-ball/contracts"
+	"github.com/orakurudata/crystal-ball/database"
+	"github.com/orakurudata/crystal-ball/endpoints"
+	"github.com/orakurudata/crystal-ball/secrets"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func takePointer(v string) *string {
@@ -50,3 +74,1018 @@ func TestSecrets(t *testing.T) {
 		t.Fatal("invalid result produced")
 	}
 }
+
+func TestEnvelopeSecrets(t *testing.T) {
+	seed, _ := secrets.GenerateKey()
+	other, _ := secrets.GenerateKey()
+	public, _ := secrets.PublicKeyFromSeed(seed)
+	otherPublic, _ := secrets.PublicKeyFromSeed(other)
+	envelope, err := secrets.EncryptEnvelope([]secrets.PublicKey{otherPublic, public}, "Hello!")
+	if err != nil {
+		t.Fatalf("encryption failed: %v", err)
+	}
+	n := Node{
+		Requests: &configuration.Requests{
+			SecretKey: seed,
+		},
+	}
+	result, err := n.UnwrapSecrets("https://asd.com/" + envelope + "/asd")
+	if err != nil {
+		t.Fatalf("secrets unwrap failed: %v", err)
+	}
+	if result != "https://asd.com/Hello!/asd" {
+		t.Fatal("invalid result produced")
+	}
+}
+
+func TestBoundSecrets(t *testing.T) {
+	seed, _ := secrets.GenerateKey()
+	public, _ := secrets.PublicKeyFromSeed(seed)
+	bound, err := secrets.EncryptBoundEnvelope([]secrets.PublicKey{public}, "api.example.com", "key")
+	if err != nil {
+		t.Fatalf("encryption failed: %v", err)
+	}
+	unbound, err := secrets.EncryptEnvelope([]secrets.PublicKey{public}, "key")
+	if err != nil {
+		t.Fatalf("encryption failed: %v", err)
+	}
+	n := Node{
+		Requests: &configuration.Requests{
+			SecretKey: seed,
+		},
+	}
+	result, err := n.UnwrapSecrets("https://api.example.com:8443/price?key=" + bound)
+	if err != nil || result != "https://api.example.com:8443/price?key=key" {
+		t.Fatalf("UnwrapSecrets() = %v, %v", result, err)
+	}
+	for _, url := range []string{
+		"https://attacker.com/?key=" + bound,
+		"https://api.example.com.attacker.com/?key=" + bound,
+		"https://attacker.com/?u=https://api.example.com/&key=" + bound,
+		"https://" + bound + ".example.com/",
+	} {
+		if _, err = n.UnwrapSecrets(url); err != secrets.ErrHostMismatch {
+			t.Errorf("UnwrapSecrets(%v) error = %v, want %v", url, err, secrets.ErrHostMismatch)
+		}
+	}
+	// Secret in the authority moves the destination after substitution:
+	// https://attacker.com/?@api.example.com/?k=TOPSECRET
+	authority, _ := secrets.EncryptBoundEnvelope([]secrets.PublicKey{public}, "api.example.com", "attacker.com/?")
+	payload, _ := secrets.EncryptBoundEnvelope([]secrets.PublicKey{public}, "api.example.com", "TOPSECRET")
+	if result, err = n.UnwrapSecrets("https://" + authority + "@api.example.com/?k=" + payload); err != secrets.ErrHostMismatch {
+		t.Errorf("UnwrapSecrets() = %v, %v, want %v", result, err, secrets.ErrHostMismatch)
+	}
+	ds := &DataSource{
+		URL:     "https://attacker.com/",
+		Method:  "GET",
+		Headers: map[string]string{"Authorization": bound},
+	}
+	if _, err = n.unwrapDataSourceSecrets(ds); err != secrets.ErrHostMismatch {
+		t.Errorf("unwrapDataSourceSecrets() error = %v, want %v", err, secrets.ErrHostMismatch)
+	}
+
+	n.Requests.RequireBoundSecrets = true
+	if _, err = n.UnwrapSecrets("https://api.example.com/?key=" + unbound); err != ErrUnboundSecret {
+		t.Errorf("UnwrapSecrets() error = %v, want %v", err, ErrUnboundSecret)
+	}
+}
+
+func TestExecuteRequestHostMismatch(t *testing.T) {
+	seed, _ := secrets.GenerateKey()
+	public, _ := secrets.PublicKeyFromSeed(seed)
+	bound, _ := secrets.EncryptBoundEnvelope([]secrets.PublicKey{public}, "api.example.com", "key")
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = w.Write([]byte("1"))
+	}))
+	defer server.Close()
+	n := Node{
+		Requests: &configuration.Requests{
+			SecretKey: seed,
+			Timeout:   time.Second,
+		},
+	}
+	_, err := n.executeRequest(context.Background(), &DataSource{URL: server.URL + "/?k=" + bound, Method: http.MethodGet}, "")
+	if err == nil || hits != 0 {
+		t.Fatalf("request with a secret bound to another host was sent, err = %v", err)
+	}
+}
+
+func TestExecuteRequestSecretRedirect(t *testing.T) {
+	seed, _ := secrets.GenerateKey()
+	public, _ := secrets.PublicKeyFromSeed(seed)
+	bound, _ := secrets.EncryptBoundEnvelope([]secrets.PublicKey{public}, "127.0.0.1", "key")
+	hits := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = w.Write([]byte("1"))
+	}))
+	defer target.Close()
+	// Both servers listen on the loopback, but the redirect leaves the host secrets are bound to
+	redirect := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, redirect+r.URL.RequestURI(), http.StatusFound)
+	}))
+	defer server.Close()
+	n := Node{
+		Requests: &configuration.Requests{
+			SecretKey: seed,
+			Timeout:   time.Second,
+		},
+	}
+	_, err := n.executeRequest(context.Background(), &DataSource{URL: server.URL + "/?k=" + bound, Method: http.MethodGet}, "")
+	if !errors.Is(err, ErrSecretRedirect) || hits != 0 {
+		t.Fatalf("secret was sent to another host after a redirect, err = %v", err)
+	}
+	result, err := n.executeRequest(context.Background(), &DataSource{URL: server.URL + "/?k=public", Method: http.MethodGet}, "regex:.*")
+	if err != nil || result != "1" || hits != 1 {
+		t.Fatalf("redirect without secrets was not followed, result = %v, err = %v", result, err)
+	}
+}
+
+func TestSecretKeyRotation(t *testing.T) {
+	oldSeed, _ := secrets.GenerateKey()
+	newSeed, _ := secrets.GenerateKey()
+	oldPublic, _ := secrets.PublicKeyFromSeed(oldSeed)
+	envelope, err := secrets.EncryptEnvelope([]secrets.PublicKey{oldPublic}, "Hello!")
+	if err != nil {
+		t.Fatalf("encryption failed: %v", err)
+	}
+	n := Node{
+		Requests: &configuration.Requests{
+			SecretKey: newSeed,
+			SecretKeys: []configuration.SecretKey{
+				{Key: oldSeed, RetireAt: time.Now().Add(time.Hour)},
+			},
+		},
+	}
+	result, err := n.UnwrapSecrets("https://asd.com/" + envelope)
+	if err != nil || result != "https://asd.com/Hello!" {
+		t.Fatalf("secret for an active old key can't be unwrapped: %v", err)
+	}
+	n = Node{
+		Requests: &configuration.Requests{
+			SecretKey: newSeed,
+			SecretKeys: []configuration.SecretKey{
+				{Key: oldSeed, RetireAt: time.Now().Add(-time.Hour)},
+			},
+		},
+	}
+	if _, err = n.UnwrapSecrets("https://asd.com/" + envelope); err == nil {
+		t.Fatal("secret for a retired key was unwrapped")
+	}
+}
+
+func TestParseDataSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		method   string
+		body     string
+		jsonBody bool
+		wantErr  bool
+	}{
+		{"test plain url", "https://asd.com/", "GET", "", false, false},
+		{"test get without body", `{"url": "https://asd.com/", "headers": {"X-Key": "value"}}`, "GET", "", false, false},
+		{"test post with string body", `{"url": "https://asd.com/", "method": "post", "body": "a=b"}`, "POST", "a=b", false, false},
+		{"test post with json body", `{"url": "https://asd.com/", "method": "POST", "body": {"query": "{ a }"}}`, "POST", `{"query": "{ a }"}`, true, false},
+		{"test get with body", `{"url": "https://asd.com/", "body": "a=b"}`, "", "", false, true},
+		{"test unsupported method", `{"url": "https://asd.com/", "method": "DELETE"}`, "", "", false, true},
+		{"test missing url", `{"method": "POST"}`, "", "", false, true},
+		{"test unknown field", `{"url": "https://asd.com/", "query": "a"}`, "", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDataSource(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDataSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Method != tt.method || got.body != tt.body || got.jsonBody != tt.jsonBody {
+				t.Errorf("ParseDataSource() = %+v, want method %v, body %v", got, tt.method, tt.body)
+			}
+		})
+	}
+}
+
+func TestDataSourceSecrets(t *testing.T) {
+	secret, _ := base64.StdEncoding.DecodeString("WcrhWaKk+bgqp4uuVMAbGn5jlF2yeufzNqBsS3O503g=")
+	n := Node{
+		Requests: &configuration.Requests{
+			SecretKey: secret,
+		},
+	}
+	ds, err := ParseDataSource(`{"url": "https://asd.com/", "method": "POST", ` +
+		`"headers": {"X-Key": "$$u8Gj3CIL1eiCBF/U2FYZpTuZNeDoqWefSUdggXZDpW4=:+qpvr5ZNIUyV1Y/RWWcgeyJ/gJFRoPQGH1nXno3dyQ89zA==$$"}, ` +
+		`"body": {"key": "$$ndydYZwNL1Uw0bX0aUyPdsZi0tC+tMv/kHeh984dMTQ=:FwrY7Fi9oyTFgldn57xJOgRt2Uu9w4dYiQeM2UZNR9ZbQfM=$$"}}`)
+	if err != nil {
+		t.Fatalf("data source decoding failed: %v", err)
+	}
+	ds, err = n.unwrapDataSourceSecrets(ds)
+	if err != nil {
+		t.Fatalf("secrets unwrap failed: %v", err)
+	}
+	r, err := ds.NewRequest(context.Background())
+	if err != nil {
+		t.Fatalf("request creation failed: %v", err)
+	}
+	if r.Header.Get("X-Key") != "Hello!" || r.Header.Get("Content-Type") != "application/json" {
+		t.Fatal("invalid headers produced")
+	}
+	body, _ := io.ReadAll(r.Body)
+	if string(body) != `{"key": "Hello2!"}` {
+		t.Fatalf("invalid body produced: %s", body)
+	}
+}
+
+func Test_normalizeResult(t *testing.T) {
+	type args struct {
+		result    string
+		aggrType  uint8
+		precision uint8
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{"test exponent", args{"1e3", AggrTypeMedian, 0}, "1000", false},
+		{"test trailing zeros", args{"1000.0", AggrTypeAverage, 2}, "1000", false},
+		{"test integer", args{" 1000\n", AggrTypeAverage, 2}, "1000", false},
+		{"test comma", args{"1,5", AggrTypeMedian, 2}, "1.5", false},
+		{"test rounding to precision", args{"3.14159", AggrTypeAverage, 2}, "3.14", false},
+		{"test zero precision is not rounded", args{"1.75", AggrTypeMedian, 0}, "1.75", false},
+		{"test negative zero", args{"-0.0", AggrTypeMedian, 2}, "0", false},
+		{"test string for average", args{"hello", AggrTypeAverage, 2}, "", true},
+		{"test NaN", args{"NaN", AggrTypeAverage, 2}, "", true},
+		{"test infinity", args{"-Inf", AggrTypeMedian, 2}, "", true},
+		{"test overflow", args{"1e400", AggrTypeMedian, 2}, "", true},
+		{"test empty result", args{"  ", AggrTypeMostFrequent, 0}, "", true},
+		{"test most frequent number", args{"1.50e1", AggrTypeMostFrequent, 0}, "15", false},
+		{"test most frequent trailing zeros", args{"1000.0", AggrTypeMostFrequent, 0}, "1000", false},
+		{"test most frequent number is not rounded", args{"1.55", AggrTypeMostFrequent, 0}, "1.55", false},
+		{"test most frequent number is rounded to precision", args{"1.555", AggrTypeMostFrequent, 2}, "1.56", false},
+		{"test most frequent leading zeros", args{"007", AggrTypeMostFrequent, 0}, "007", false},
+		{"test most frequent leading zeros with precision", args{"007.50", AggrTypeMostFrequent, 1}, "7.5", false},
+		{"test most frequent fraction", args{"0.50", AggrTypeMostFrequent, 0}, "0.5", false},
+		{"test most frequent overflow", args{"1e400", AggrTypeMostFrequent, 0}, "", true},
+		{"test most frequent string", args{" Hello World ", AggrTypeMostFrequent, 0}, "Hello World", false},
+		{"test most frequent NaN", args{"nan", AggrTypeMostFrequent, 0}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeResult(tt.args.result, tt.args.aggrType, tt.args.precision)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeResult() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAbortRequest(t *testing.T) {
+	db, err := database.OpenConnection("file::memory:")
+	if err != nil {
+		t.Fatalf("OpenConnection returned an error: %v", err)
+	}
+	n := &Node{DB: db, ActiveRequests: map[[32]byte]*job{}, ActiveRequestsMutex: &sync.Mutex{}}
+	active, idle := [32]byte{1}, [32]byte{2}
+	for _, id := range [][32]byte{active, idle} {
+		err = db.AddRequest(&database.Request{RequestID: id[:], ExecutionTimestamp: time.Now(), Status: database.StatusReceived})
+		if err != nil {
+			t.Fatalf("AddRequest returned an error: %v", err)
+		}
+	}
+
+	ctx := n.startJob(active, time.Now())
+	n.abortRequest(active, database.StatusCanceled)
+	if ctx.Err() == nil {
+		t.Fatalf("job context was not canceled")
+	}
+	// Status is recorded when the job exits, so that the job can't overwrite it
+	if rec, _ := db.GetRequest(active[:]); rec.Status != database.StatusReceived {
+		t.Fatalf("status changed before the job exited, got = %v", rec.Status)
+	}
+	n.finishJob(active)
+	if rec, _ := db.GetRequest(active[:]); rec.Status != database.StatusCanceled {
+		t.Fatalf("wrong status of aborted job, want = %v, got = %v", database.StatusCanceled, rec.Status)
+	}
+	if n.isActive(active) {
+		t.Fatalf("finished job is still active")
+	}
+
+	n.abortRequest(idle, database.StatusFulfilled)
+	if rec, _ := db.GetRequest(idle[:]); rec.Status != database.StatusFulfilled {
+		t.Fatalf("wrong status of closed request, want = %v, got = %v", database.StatusFulfilled, rec.Status)
+	}
+}
+
+func Test_submissionStatus(t *testing.T) {
+	oracle := common.Address{1}
+	other := []contracts.IOrakuruCoreResponse{{SubmittedBy: common.Address{2}}, {SubmittedBy: common.Address{3}}}
+	own := append(other, contracts.IOrakuruCoreResponse{SubmittedBy: oracle})
+	tests := []struct {
+		name      string
+		responses []contracts.IOrakuruCoreResponse
+		threshold int64
+		want      database.RequestStatus
+	}{
+		{"test no responses", nil, 3, ""},
+		{"test below threshold", other, 3, ""},
+		{"test threshold reached", other, 2, database.StatusFulfilled},
+		{"test already responded", own, 5, database.StatusResponded},
+		{"test zero threshold", other, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := submissionStatus(tt.responses, big.NewInt(tt.threshold), oracle); got != tt.want {
+				t.Errorf("submissionStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	n := &Node{ActiveRequests: map[[32]byte]*job{}, ActiveRequestsMutex: &sync.Mutex{}, heads: newHeadTracker()}
+	n.halted, n.halt = context.WithCancel(context.Background())
+	// Node context is already canceled, but heads are still tracked for the drained jobs
+	go n.heads.run(n.halted, func(context.Context) (uint64, error) {
+		return uint64(time.Now().Unix()), nil
+	})
+	imminent, postponed := [32]byte{1}, [32]byte{2}
+	imminentTime := time.Now().Add(time.Second)
+	imminentCtx := n.startJob(imminent, imminentTime)
+	postponedCtx := n.startJob(postponed, time.Now().Add(time.Hour))
+	go func() {
+		<-postponedCtx.Done()
+		n.finishJob(postponed)
+	}()
+	go func() {
+		if err := n.heads.waitFor(imminentCtx, imminentTime); err != nil {
+			t.Errorf("imminent job was canceled")
+		}
+		n.finishJob(imminent)
+	}()
+	n.Drain(3 * time.Second)
+	if n.isActive(imminent) || n.isActive(postponed) {
+		t.Fatalf("jobs are still active after drain")
+	}
+	if n.halted.Err() == nil {
+		t.Fatalf("node was not halted after drain")
+	}
+}
+
+func TestDrainExecutorTimeout(t *testing.T) {
+	n := &Node{ActiveRequests: map[[32]byte]*job{}, ActiveRequestsMutex: &sync.Mutex{}, executorDone: make(chan struct{})}
+	id := [32]byte{1}
+	ctx := n.startJob(id, time.Now())
+	go func() {
+		// Job only finishes when it's canceled
+		<-ctx.Done()
+		n.finishJob(id)
+	}()
+	start := time.Now()
+	n.Drain(50 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("drain took %v after executor timeout", elapsed)
+	}
+	if n.isActive(id) {
+		t.Fatalf("job is still active after drain")
+	}
+}
+
+func TestAdvanceCheckpoint(t *testing.T) {
+	db, err := database.OpenConnection("file::memory:")
+	if err != nil {
+		t.Fatalf("OpenConnection returned an error: %v", err)
+	}
+	n := &Node{DB: db}
+	if _, ok := n.loadCheckpoint(); ok {
+		t.Fatalf("checkpoint exists in a new database")
+	}
+	n.advanceCheckpoint(100)
+	n.advanceCheckpoint(90)
+	if block, _ := n.loadCheckpoint(); block != 100 {
+		t.Fatalf("checkpoint moved backwards, want = 100, got = %v", block)
+	}
+	n.backfilling = true
+	n.advanceCheckpoint(200)
+	if block, _ := n.loadCheckpoint(); block != 100 {
+		t.Fatalf("checkpoint moved during backfill, want = 100, got = %v", block)
+	}
+	n.finishBackfill(150)
+	if block, _ := n.loadCheckpoint(); block != 150 {
+		t.Fatalf("checkpoint was not moved after backfill, want = 150, got = %v", block)
+	}
+}
+
+func TestBackfillError(t *testing.T) {
+	BackfillRetryDelay = 10 * time.Millisecond
+	chain := &fakeChain{head: 150, failures: 1000}
+	n := newTestNode(t, chain)
+	n.advanceCheckpoint(100)
+	sink := make(chan *contracts.IOrakuruCoreRequested, 10)
+
+	// Backfill keeps failing until it is stopped, and missed blocks must not be skipped meanwhile
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	n.backfill(ctx, sink)
+	n.advanceCheckpoint(200)
+	if block, _ := n.loadCheckpoint(); block != 100 {
+		t.Fatalf("checkpoint moved over blocks that were not scanned, want = 100, got = %v", block)
+	}
+	if len(chain.takeQueries()) < 2 {
+		t.Fatalf("failed backfill was not retried")
+	}
+
+	chain.mutex.Lock()
+	chain.failures = 0
+	chain.mutex.Unlock()
+	n.backfill(context.Background(), sink)
+	if block, _ := n.loadCheckpoint(); block != 150 {
+		t.Fatalf("checkpoint was not moved after successful backfill, want = 150, got = %v", block)
+	}
+}
+
+func TestHeadTracker(t *testing.T) {
+	h := newHeadTracker()
+	h.set(100)
+	err := h.waitFor(context.Background(), time.Unix(90, 0))
+	if err != nil {
+		t.Fatalf("waitFor returned an error: %v", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		h.set(110)
+	}()
+	err = h.waitFor(context.Background(), time.Unix(105, 0))
+	if err != nil {
+		t.Fatalf("waitFor returned an error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = h.waitFor(ctx, time.Unix(200, 0))
+	if err == nil {
+		t.Fatalf("waitFor returned before head reached the time")
+	}
+}
+
+func Test_reduceSamples(t *testing.T) {
+	tests := []struct {
+		name        string
+		samples     []string
+		aggrType    uint8
+		onlyNumbers bool
+		want        string
+		wantErr     bool
+	}{
+		{"test single sample", []string{"1.5"}, AggrTypeMedian, false, "1.5", false},
+		{"test odd median", []string{"3", "1", "2"}, AggrTypeMedian, false, "2", false},
+		{"test even median", []string{"4", "1", "2", "3"}, AggrTypeMedian, false, "2.5", false},
+		{"test average", []string{"1", "2", "4,5"}, AggrTypeAverage, false, "2.5", false},
+		{"test non-numeric samples are dropped", []string{"1", "error", "3"}, AggrTypeAverage, false, "2", false},
+		{"test no numeric samples", []string{"error"}, AggrTypeMedian, false, "", true},
+		{"test most frequent", []string{"a", "b", "a"}, AggrTypeMostFrequent, false, "a", false},
+		{"test most frequent tie", []string{"a", "b"}, AggrTypeMostFrequent, false, "b", false},
+		{"test most frequent numbers", []string{"007", "7", "007"}, AggrTypeMostFrequent, false, "007", false},
+		{"test only numbers", []string{"a", "a", "5"}, AggrTypeMostFrequent, true, "5", false},
+		{"test only numbers without numbers", []string{"a"}, AggrTypeMostFrequent, true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reduceSamples(tt.samples, tt.aggrType, tt.onlyNumbers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reduceSamples() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("reduceSamples() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if distinctSamples([]string{"1.0", " 1", "1,0"}) != 1 {
+		t.Errorf("equal numbers are counted as distinct samples")
+	}
+}
+
+// fakeChain serves eth methods used by the node from memory
+type fakeChain struct {
+	mutex   sync.Mutex
+	head    uint64
+	logs    []types.Log
+	calls   map[string]hexutil.Bytes
+	queries [][2]uint64
+	// receipts contains statuses of mined transactions
+	receipts map[common.Hash]uint64
+	// failures is the amount of the next log queries that fail
+	failures int
+}
+
+type fakeFilter struct {
+	FromBlock hexutil.Uint64   `json:"fromBlock"`
+	ToBlock   hexutil.Uint64   `json:"toBlock"`
+	Address   []common.Address `json:"address"`
+	Topics    [][]common.Hash  `json:"topics"`
+}
+
+type fakeCall struct {
+	To   common.Address `json:"to"`
+	Data hexutil.Bytes  `json:"data"`
+}
+
+func (f *fakeChain) BlockNumber() hexutil.Uint64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return hexutil.Uint64(f.head)
+}
+
+func (f *fakeChain) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(97))
+}
+
+func (f *fakeChain) GetLogs(filter fakeFilter) ([]types.Log, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.queries = append(f.queries, [2]uint64{uint64(filter.FromBlock), uint64(filter.ToBlock)})
+	if f.failures > 0 {
+		f.failures--
+		return nil, errors.New("query timeout")
+	}
+	out := []types.Log{}
+	for _, l := range f.logs {
+		if l.BlockNumber < uint64(filter.FromBlock) || l.BlockNumber > uint64(filter.ToBlock) {
+			continue
+		}
+		if len(filter.Address) > 0 && filter.Address[0] != l.Address {
+			continue
+		}
+		if len(filter.Topics) > 0 && len(filter.Topics[0]) > 0 && filter.Topics[0][0] != l.Topics[0] {
+			continue
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func (f *fakeChain) Call(call fakeCall, _ string) (hexutil.Bytes, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(call.Data) < 4 {
+		return nil, errors.New("execution reverted")
+	}
+	out, ok := f.calls[call.To.Hex()+hexutil.Encode(call.Data[:4])]
+	if !ok {
+		return nil, errors.New("execution reverted")
+	}
+	return out, nil
+}
+
+func (f *fakeChain) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	status, ok := f.receipts[hash]
+	if !ok {
+		return nil, nil
+	}
+	return &types.Receipt{Status: status, TxHash: hash, Logs: []*types.Log{}, BlockNumber: big.NewInt(int64(f.head))}, nil
+}
+
+func (f *fakeChain) setHead(head uint64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.head = head
+}
+
+// takeQueries returns block ranges of log queries made since the previous call
+func (f *fakeChain) takeQueries() [][2]uint64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	out := f.queries
+	f.queries = nil
+	return out
+}
+
+// setCall makes calls of the contract method return outputs
+func (f *fakeChain) setCall(t *testing.T, contract common.Address, contractABI, method string, outputs ...interface{}) {
+	parsed, err := abi.JSON(strings.NewReader(contractABI))
+	if err != nil {
+		t.Fatalf("could not parse ABI: %v", err)
+	}
+	out, err := parsed.Methods[method].Outputs.Pack(outputs...)
+	if err != nil {
+		t.Fatalf("could not pack outputs of %v: %v", method, err)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.calls == nil {
+		f.calls = make(map[string]hexutil.Bytes)
+	}
+	f.calls[contract.Hex()+hexutil.Encode(parsed.Methods[method].ID)] = out
+}
+
+// emit adds an event of the core contract, args are given in the order of event inputs
+func (f *fakeChain) emit(t *testing.T, core common.Address, block uint64, name string, args ...interface{}) {
+	parsed, err := abi.JSON(strings.NewReader(contracts.IOrakuruCoreABI))
+	if err != nil {
+		t.Fatalf("could not parse ABI: %v", err)
+	}
+	ev := parsed.Events[name]
+	topics := []common.Hash{ev.ID}
+	var data []interface{}
+	for i, input := range ev.Inputs {
+		if !input.Indexed {
+			data = append(data, args[i])
+			continue
+		}
+		topic, err := abi.MakeTopics([]interface{}{args[i]})
+		if err != nil {
+			t.Fatalf("could not make topic: %v", err)
+		}
+		topics = append(topics, topic[0][0])
+	}
+	packed, err := ev.Inputs.NonIndexed().Pack(data...)
+	if err != nil {
+		t.Fatalf("could not pack %v event: %v", name, err)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.logs = append(f.logs, types.Log{Address: core, Topics: topics, Data: packed, BlockNumber: block})
+}
+
+var testCore = common.HexToAddress("0x00000000000000000000000000000000000c0de1")
+
+func newTestNode(t *testing.T, chain *fakeChain) *Node {
+	server := rpc.NewServer()
+	err := server.RegisterName("eth", chain)
+	if err != nil {
+		t.Fatalf("could not register service: %v", err)
+	}
+	pool, err := endpoints.NewPool(context.Background(), endpoints.NewEndpoint("http://fake", rpc.DialInProc(server)))
+	if err != nil {
+		t.Fatalf("NewPool returned an error: %v", err)
+	}
+	db, err := database.OpenConnection("file::memory:")
+	if err != nil {
+		t.Fatalf("OpenConnection returned an error: %v", err)
+	}
+	core, err := contracts.NewIOrakuruCore(testCore, pool)
+	if err != nil {
+		t.Fatalf("NewIOrakuruCore returned an error: %v", err)
+	}
+	return &Node{
+		Requests: &configuration.Requests{},
+		Web3: &configuration.Web3{Events: configuration.Events{
+			Source:       configuration.EventSourcePoll,
+			PollInterval: 10 * time.Millisecond,
+		}},
+		DB:                  db,
+		Chain:               &configuration.Chain{ExecutionWindow: time.Minute},
+		Client:              pool,
+		CoreAddress:         testCore,
+		Core:                core,
+		ActiveRequests:      map[[32]byte]*job{},
+		ActiveRequestsMutex: &sync.Mutex{},
+		coreChanged:         make(chan struct{}, 1),
+	}
+}
+
+func testRequest(id byte, executionTime time.Time) []interface{} {
+	return []interface{}{[32]byte{id}, "https://93.184.216.34/", "", common.Address{1}, uint8(0), uint8(0),
+		big.NewInt(executionTime.Unix()), big.NewInt(time.Now().Unix())}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (n *Node) polledBlock() uint64 {
+	n.checkpointMutex.Lock()
+	defer n.checkpointMutex.Unlock()
+	return n.pollNext
+}
+
+func TestPollEvents(t *testing.T) {
+	chain := &fakeChain{head: 9000}
+	chain.emit(t, testCore, 2, "Requested", testRequest(1, time.Now())...)
+	chain.emit(t, testCore, 5000, "Canceled", [32]byte{2}, big.NewInt(1))
+	chain.emit(t, testCore, 8500, "Fulfilled", [32]byte{3}, []byte{1}, big.NewInt(1))
+	chain.emit(t, common.Address{2}, 8600, "Canceled", [32]byte{4}, big.NewInt(1))
+	n := newTestNode(t, chain)
+	n.setPolledBlock(1)
+	sink := make(chan *contracts.IOrakuruCoreRequested, 10)
+	canceled := make(chan *contracts.IOrakuruCoreCanceled, 10)
+	fulfilled := make(chan *contracts.IOrakuruCoreFulfilled, 10)
+
+	sub, err := n.pollEvents(sink, canceled, fulfilled)
+	if err != nil {
+		t.Fatalf("pollEvents returned an error: %v", err)
+	}
+	waitFor(t, "polling of all blocks", func() bool { return n.polledBlock() == 9001 })
+	// Blocks are queried in pages, each page for every kind of events
+	want := [][2]uint64{{1, 4000}, {4001, 8000}, {8001, 9000}}
+	queries := chain.takeQueries()
+	if len(queries) != 3*len(want) {
+		t.Fatalf("wrong amount of log queries, want = %v, got = %v", 3*len(want), queries)
+	}
+	for i, q := range queries {
+		if q != want[i/3] {
+			t.Fatalf("wrong range of query %v, want = %v, got = %v", i, want[i/3], q)
+		}
+	}
+	if len(sink) != 1 || len(canceled) != 1 || len(fulfilled) != 1 {
+		t.Fatalf("wrong events received: requested = %v, canceled = %v, fulfilled = %v", len(sink), len(canceled), len(fulfilled))
+	}
+	if ev := <-sink; ev.RequestId != [32]byte{1} || ev.DataSource != "https://93.184.216.34/" {
+		t.Fatalf("wrong request received: %+v", ev)
+	}
+	if ev := <-canceled; ev.RequestId != [32]byte{2} {
+		t.Fatalf("wrong canceled request received: %x", ev.RequestId)
+	}
+
+	// Polling fails, and has to resume from the first block that wasn't polled after resubscription
+	chain.mutex.Lock()
+	chain.failures = 1
+	chain.mutex.Unlock()
+	chain.emit(t, testCore, 9050, "Canceled", [32]byte{5}, big.NewInt(1))
+	chain.setHead(9100)
+	select {
+	case <-sub.Err():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("failed polling did not fail the subscription")
+	}
+	sub.Unsubscribe()
+	sub, err = n.pollEvents(sink, canceled, fulfilled)
+	if err != nil {
+		t.Fatalf("pollEvents returned an error: %v", err)
+	}
+	defer sub.Unsubscribe()
+	select {
+	case ev := <-canceled:
+		if ev.RequestId != [32]byte{5} {
+			t.Fatalf("wrong canceled request received: %x", ev.RequestId)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("event emitted during failure was missed")
+	}
+	for _, q := range chain.takeQueries() {
+		if q[0] != 9001 {
+			t.Fatalf("polling did not resume from the last polled block, got = %v", q)
+		}
+	}
+}
+
+func TestMergeSubscriptions(t *testing.T) {
+	failed := make(chan error, 1)
+	stopped := make(chan struct{})
+	first := event.NewSubscription(func(quit <-chan struct{}) error {
+		select {
+		case err := <-failed:
+			return err
+		case <-quit:
+			return nil
+		}
+	})
+	second := event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		close(stopped)
+		return nil
+	})
+	sub := mergeSubscriptions(first, second)
+	defer sub.Unsubscribe()
+	lost := errors.New("connection lost")
+	failed <- lost
+	select {
+	case err := <-sub.Err():
+		if err != lost {
+			t.Fatalf("wrong error, want = %v, got = %v", lost, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("failure of a subscription was not propagated")
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatalf("other subscriptions were not unsubscribed")
+	}
+}
+
+func TestHandlerLoopDuplicates(t *testing.T) {
+	chain := &fakeChain{head: 100}
+	executionTime := time.Now().Add(time.Hour)
+	chain.emit(t, testCore, 101, "Requested", testRequest(1, executionTime)...)
+	n := newTestNode(t, chain)
+	n.setPolledBlock(101)
+	sink := make(chan *contracts.IOrakuruCoreRequested, 10)
+	canceled := make(chan *contracts.IOrakuruCoreCanceled, 10)
+	fulfilled := make(chan *contracts.IOrakuruCoreFulfilled, 10)
+	sub, err := n.pollEvents(sink, canceled, fulfilled)
+	if err != nil {
+		t.Fatalf("pollEvents returned an error: %v", err)
+	}
+	defer sub.Unsubscribe()
+	executed := testutil.ToFloat64(monitoring.ExecutedJobsCounter)
+
+	// The same request is delivered by the subscription and by the poller
+	sink <- &contracts.IOrakuruCoreRequested{
+		RequestId:          [32]byte{1},
+		DataSource:         "https://93.184.216.34/",
+		CallbackAddr:       common.Address{1},
+		ExecutionTimestamp: big.NewInt(executionTime.Unix()),
+		Timestamp:          big.NewInt(time.Now().Unix()),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.HandlerLoop(ctx, sub, sink, canceled, fulfilled)
+		close(done)
+	}()
+	chain.setHead(101)
+	waitFor(t, "delivery of all events", func() bool { return n.polledBlock() == 102 && len(sink) == 0 })
+	cancel()
+	<-done
+
+	n.ActiveRequestsMutex.Lock()
+	for _, j := range n.ActiveRequests {
+		j.cancel()
+	}
+	n.ActiveRequestsMutex.Unlock()
+	waited := make(chan struct{})
+	go func() {
+		n.jobs.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatalf("duplicate job was started")
+	}
+	if got := testutil.ToFloat64(monitoring.ExecutedJobsCounter) - executed; got != 1 {
+		t.Fatalf("request was executed %v times", got)
+	}
+}
+
+func TestResolveContracts(t *testing.T) {
+	registry, staking := common.Address{0xaa}, common.Address{0xbb}
+	oracle := common.Address{0xcc}
+	chain := &fakeChain{head: 100}
+	chain.setCall(t, registry, contracts.IAddressRegistryABI, "getOrakuruCoreAddr", testCore)
+	chain.setCall(t, registry, contracts.IAddressRegistryABI, "getStakingAddr", staking)
+	chain.setCall(t, testCore, contracts.IOrakuruCoreABI, "addressRegistry", registry)
+	chain.setCall(t, staking, contracts.IStakingABI, "isRegisteredOracle", true)
+	tests := []struct {
+		name     string
+		registry string
+		core     string
+		warning  string
+	}{
+		{"test core resolved through the registry", registry.Hex(), "", ""},
+		{"test registry resolved through the core", "", testCore.Hex(), ""},
+		{"test core differing from the registry", registry.Hex(), common.Address{0xdd}.Hex(), "configured core address differs from the registry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			defer func(logger zerolog.Logger) { log.Logger = logger }(log.Logger)
+			log.Logger = zerolog.New(&output)
+
+			n := newTestNode(t, chain)
+			n.Web3.AddressRegistry = tt.registry
+			n.Web3.OrakuruCore = tt.core
+			n.CoreAddress, n.Core = common.Address{}, nil
+			err := n.resolveContracts(context.Background())
+			if err != nil {
+				t.Fatalf("resolveContracts returned an error: %v", err)
+			}
+			if n.CoreAddress != testCore || n.core() == nil {
+				t.Fatalf("wrong core resolved, want = %v, got = %v", testCore, n.CoreAddress)
+			}
+			registered, err := n.staking().IsRegisteredOracle(nil, oracle)
+			if err != nil || !registered {
+				t.Fatalf("staking contract was not resolved, err = %v", err)
+			}
+			if tt.warning != "" && !strings.Contains(output.String(), tt.warning) {
+				t.Fatalf("warning was not logged, want = %v, got = %v", tt.warning, output.String())
+			}
+		})
+	}
+
+	n := newTestNode(t, chain)
+	if err := n.resolveContracts(context.Background()); err != ErrNoCoreAddress {
+		t.Fatalf("resolveContracts failed, want = %v, got = %v", ErrNoCoreAddress, err)
+	}
+}
+
+func TestRefreshCore(t *testing.T) {
+	registry, staking, upgraded := common.Address{0xaa}, common.Address{0xbb}, common.Address{0xee}
+	tests := []struct {
+		name     string
+		registry string
+		core     string
+	}{
+		{"test configured registry", registry.Hex(), ""},
+		{"test registry discovered through the core", "", testCore.Hex()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &fakeChain{head: 100}
+			chain.setCall(t, registry, contracts.IAddressRegistryABI, "getOrakuruCoreAddr", testCore)
+			chain.setCall(t, registry, contracts.IAddressRegistryABI, "getStakingAddr", staking)
+			chain.setCall(t, testCore, contracts.IOrakuruCoreABI, "addressRegistry", registry)
+			n := newTestNode(t, chain)
+			n.Web3.AddressRegistry = tt.registry
+			n.Web3.OrakuruCore = tt.core
+			err := n.resolveContracts(context.Background())
+			if err != nil {
+				t.Fatalf("resolveContracts returned an error: %v", err)
+			}
+
+			err = n.refreshCore(context.Background())
+			if err != nil {
+				t.Fatalf("refreshCore returned an error: %v", err)
+			}
+			select {
+			case <-n.coreChanged:
+				t.Fatalf("core change was signaled without an upgrade")
+			default:
+			}
+
+			chain.setCall(t, registry, contracts.IAddressRegistryABI, "getOrakuruCoreAddr", upgraded)
+			err = n.refreshCore(context.Background())
+			if err != nil {
+				t.Fatalf("refreshCore returned an error: %v", err)
+			}
+			select {
+			case <-n.coreChanged:
+			default:
+				t.Fatalf("core upgrade was not signaled")
+			}
+			if n.CoreAddress != upgraded {
+				t.Fatalf("core was not switched, want = %v, got = %v", upgraded, n.CoreAddress)
+			}
+		})
+	}
+}
+
+func TestReconcileJournal(t *testing.T) {
+	mined, reverted := common.Hash{1}, common.Hash{2}
+	chain := &fakeChain{head: 100, receipts: map[common.Hash]uint64{
+		mined:    types.ReceiptStatusSuccessful,
+		reverted: types.ReceiptStatusFailed,
+	}}
+	n := newTestNode(t, chain)
+	tests := []struct {
+		name    string
+		status  database.RequestStatus
+		tx      common.Hash
+		pending bool
+		want    database.RequestStatus
+	}{
+		{"test mined submission", database.StatusSubmitted, mined, false, database.StatusMined},
+		{"test reverted submission", database.StatusSubmitted, reverted, true, database.StatusReverted},
+		{"test lost submission of pending request", database.StatusSubmitted, common.Hash{3}, true, database.StatusExecuted},
+		{"test lost submission of closed request", database.StatusSubmitted, common.Hash{4}, false, database.StatusDropped},
+		{"test pending request", database.StatusReceived, common.Hash{}, true, database.StatusReceived},
+		{"test closed request", database.StatusExecuted, common.Hash{}, false, database.StatusExpired},
+	}
+	var pending [][32]byte
+	for i, tt := range tests {
+		id := [32]byte{byte(i + 1)}
+		err := n.DB.AddRequest(&database.Request{RequestID: id[:], ExecutionTimestamp: time.Now(), Status: tt.status})
+		if err != nil {
+			t.Fatalf("AddRequest returned an error: %v", err)
+		}
+		if tt.status == database.StatusSubmitted {
+			n.setRequestTransaction(id, tt.tx.Hex())
+		}
+		if tt.pending {
+			pending = append(pending, id)
+		}
+	}
+	err := n.reconcileJournal(context.Background(), pending)
+	if err != nil {
+		t.Fatalf("reconcileJournal returned an error: %v", err)
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := [32]byte{byte(i + 1)}
+			rec, err := n.DB.GetRequest(id[:])
+			if err != nil {
+				t.Fatalf("GetRequest returned an error: %v", err)
+			}
+			if rec.Status != tt.want {
+				t.Fatalf("wrong status, want = %v, got = %v", tt.want, rec.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"github.com/shopspring/decimal"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrEmptyResult = errors.New("result is empty")
+	ErrNotANumber  = errors.New("wanted a number, got a string")
+	ErrNotFinite   = errors.New("result is not a finite number")
+
+	// NumberRegexp matches decimal numbers with an optional exponent
+	NumberRegexp = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+	// LeadingZerosRegexp matches numbers with significant leading zeros, e.g. "007" but not "0.5"
+	LeadingZerosRegexp = regexp.MustCompile(`^[+-]?0\d`)
+)
+
+// normalizeResult converts result into a canonical form, so that all oracles submit the same string for the same value.
+// Numbers are formatted without exponent and trailing zeros, e.g. "1e3", "1000.0" and "1000" all become "1000",
+// and rounded to precision decimal places if it is set. Results of Median and Average requests have to be numbers.
+// MostFrequent results that are not numbers, or are zero-padded like "007" while precision is not set, are kept as is.
+func normalizeResult(result string, aggrType, precision uint8) (string, error) {
+	result = strings.TrimSpace(result)
+	if result == "" {
+		return "", ErrEmptyResult
+	}
+	if f, err := strconv.ParseFloat(result, 64); err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return "", ErrNotFinite
+	}
+	if aggrType != AggrTypeAverage && aggrType != AggrTypeMedian {
+		d, err := parseNumber(result)
+		if errors.Is(err, ErrNotFinite) {
+			return "", err
+		}
+		// Zero-padded numbers are usually identifiers, unless the request asks for a precision
+		if err != nil || (precision == 0 && LeadingZerosRegexp.MatchString(result)) {
+			return result, nil
+		}
+		return roundNumber(d, precision), nil
+	}
+	if !validateNumber(&result) {
+		return "", ErrNotANumber
+	}
+	d, err := parseNumber(result)
+	if err != nil {
+		return "", err
+	}
+	return roundNumber(d, precision), nil
+}
+
+// roundNumber formats d rounded to precision decimal places. Zero precision is the default,
+// it doesn't mean that the result has to be an integer, so d is not rounded then.
+func roundNumber(d decimal.Decimal, precision uint8) string {
+	if precision != 0 {
+		d = d.Round(int32(precision))
+	}
+	return d.String()
+}
+
+func parseNumber(value string) (decimal.Decimal, error) {
+	if !NumberRegexp.MatchString(value) {
+		return decimal.Decimal{}, ErrNotANumber
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		// Number is too large for float64, other oracles might not handle it
+		return decimal.Decimal{}, ErrNotFinite
+	}
+	return decimal.NewFromString(value)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/orakurudata/crystal-ball/contracts"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// CoreRefreshInterval defines how often the core address is resolved through the address registry again
+const CoreRefreshInterval = 10 * time.Minute
+
+var (
+	ErrNoCoreAddress = errors.New("neither core address nor address registry is configured")
+)
+
+// resolveContracts finds the core, the address registry and the staking contracts. When the address registry
+// is configured, the core is resolved through it, otherwise the registry is taken from the core.
+func (n *Node) resolveContracts(ctx context.Context) error {
+	opts := &bind.CallOpts{Context: ctx}
+	var coreAddr common.Address
+	var registry *contracts.IAddressRegistry
+	var err error
+	switch {
+	case n.Web3.AddressRegistry != "":
+		registry, err = contracts.NewIAddressRegistry(common.HexToAddress(n.Web3.AddressRegistry), n.Client)
+		if err != nil {
+			return err
+		}
+		coreAddr, err = registry.GetOrakuruCoreAddr(opts)
+		if err != nil {
+			return err
+		}
+		if n.Web3.OrakuruCore != "" && common.HexToAddress(n.Web3.OrakuruCore) != coreAddr {
+			log.Warn().Str("configured", n.Web3.OrakuruCore).Str("registry", coreAddr.String()).
+				Msg("configured core address differs from the registry, using the registry")
+		}
+	case n.Web3.OrakuruCore != "":
+		coreAddr = common.HexToAddress(n.Web3.OrakuruCore)
+		var core *contracts.IOrakuruCore
+		core, err = contracts.NewIOrakuruCore(coreAddr, n.Client)
+		if err != nil {
+			return err
+		}
+		var registryAddr common.Address
+		registryAddr, err = core.AddressRegistry(opts)
+		if err != nil {
+			return err
+		}
+		registry, err = contracts.NewIAddressRegistry(registryAddr, n.Client)
+		if err != nil {
+			return err
+		}
+	default:
+		return ErrNoCoreAddress
+	}
+	return n.bindContracts(ctx, coreAddr, registry)
+}
+
+// bindContracts switches the node to the core at coreAddr and the staking contract taken from registry
+func (n *Node) bindContracts(ctx context.Context, coreAddr common.Address, registry *contracts.IAddressRegistry) error {
+	if !n.Chain.IsKnownCore(coreAddr) {
+		log.Warn().Str("core", coreAddr.String()).Msg("core address is not known for this chain")
+	}
+	core, err := contracts.NewIOrakuruCore(coreAddr, n.Client)
+	if err != nil {
+		return err
+	}
+	stakingAddr, err := registry.GetStakingAddr(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return err
+	}
+	staking, err := contracts.NewIStaking(stakingAddr, n.Client)
+	if err != nil {
+		return err
+	}
+
+	n.contractsMutex.Lock()
+	defer n.contractsMutex.Unlock()
+	n.CoreAddress = coreAddr
+	n.Core = core
+	n.Registry = registry
+	n.Staking = staking
+	return nil
+}
+
+// core returns binding of the current core contract
+func (n *Node) core() *contracts.IOrakuruCore {
+	n.contractsMutex.RLock()
+	defer n.contractsMutex.RUnlock()
+	return n.Core
+}
+
+// staking returns binding of the current staking contract
+func (n *Node) staking() *contracts.IStaking {
+	n.contractsMutex.RLock()
+	defer n.contractsMutex.RUnlock()
+	return n.Staking
+}
+
+// watchRegistry periodically resolves the core through the address registry, so that a core upgrade is picked up.
+// When only the core is configured, the registry it points to is watched. When the core changes, subscriptions
+// are recreated for the new one.
+func (n *Node) watchRegistry(ctx context.Context) {
+	ticker := time.NewTicker(CoreRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		err := n.refreshCore(ctx)
+		if err != nil {
+			log.Error().Err(err).Caller().Msg("could not resolve core address through the registry")
+		}
+	}
+}
+
+// refreshCore resolves the core through the current registry again, and signals coreChanged if it was upgraded
+func (n *Node) refreshCore(ctx context.Context) error {
+	n.contractsMutex.RLock()
+	previous, registry := n.CoreAddress, n.Registry
+	n.contractsMutex.RUnlock()
+	current, err := registry.GetOrakuruCoreAddr(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return err
+	}
+	if current == previous {
+		return nil
+	}
+	err = n.bindContracts(ctx, current, registry)
+	if err != nil {
+		return err
+	}
+	log.Warn().Str("from", previous.String()).Str("to", current.String()).Msg("core contract was upgraded, switching to the new one")
+	select {
+	case n.coreChanged <- struct{}{}:
+	default:
+	}
+	return nil
+}
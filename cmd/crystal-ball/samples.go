@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/orakurudata/crystal-ball/executor/selector"
+	"github.com/shopspring/decimal"
+	"sort"
+	"strings"
+)
+
+// sampleNumber parses a sample as a decimal number, accepting comma as a decimal separator
+func sampleNumber(sample string) (decimal.Decimal, bool) {
+	value, ok := selector.NormalizeNumber(strings.TrimSpace(sample))
+	if !ok {
+		return decimal.Decimal{}, false
+	}
+	d, err := parseNumber(value)
+	return d, err == nil
+}
+
+// reduceSamples derives the result from samples of the data source according to the aggregation type of the request.
+// Median and Average requests get median and mean of numeric samples, other samples are dropped.
+// MostFrequent requests get the most frequent sample, the latest one wins a tie.
+// If onlyNumbers is set, non-numeric samples are dropped for any aggregation type.
+func reduceSamples(samples []string, aggrType uint8, onlyNumbers bool) (string, error) {
+	numeric := aggrType == AggrTypeMedian || aggrType == AggrTypeAverage || onlyNumbers
+	var numbers []decimal.Decimal
+	var values []string
+	for _, s := range samples {
+		s = strings.TrimSpace(s)
+		if d, ok := sampleNumber(s); ok {
+			numbers = append(numbers, d)
+			// MostFrequent compares samples as strings, "007" and "7" might be different values
+			values = append(values, s)
+		} else if !numeric {
+			values = append(values, s)
+		}
+	}
+	if numeric && len(numbers) == 0 {
+		return "", ErrNotANumber
+	}
+	if len(values) == 0 {
+		return "", ErrEmptyResult
+	}
+
+	switch aggrType {
+	case AggrTypeMedian:
+		sort.Slice(numbers, func(i, j int) bool {
+			return numbers[i].LessThan(numbers[j])
+		})
+		mid := len(numbers) / 2
+		if len(numbers)%2 == 1 {
+			return numbers[mid].String(), nil
+		}
+		return numbers[mid-1].Add(numbers[mid]).Div(decimal.NewFromInt(2)).String(), nil
+	case AggrTypeAverage:
+		sum := decimal.Zero
+		for _, d := range numbers {
+			sum = sum.Add(d)
+		}
+		return sum.Div(decimal.NewFromInt(int64(len(numbers)))).String(), nil
+	default:
+		counts := make(map[string]int, len(values))
+		best := ""
+		for _, v := range values {
+			counts[v]++
+			if counts[v] >= counts[best] {
+				best = v
+			}
+		}
+		return best, nil
+	}
+}
+
+// distinctSamples returns the amount of different values among samples
+func distinctSamples(samples []string) int {
+	seen := make(map[string]bool, len(samples))
+	for _, s := range samples {
+		s = strings.TrimSpace(s)
+		if d, ok := sampleNumber(s); ok {
+			s = d.String()
+		}
+		seen[s] = true
+	}
+	return len(seen)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/orakurudata/crystal-ball/cmd/crystal-ball/monitoring"
+	"github.com/orakurudata/crystal-ball/contracts"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpClient returns HTTP client shared by all jobs, so that connections opened by prewarm are reused
+func (n *Node) httpClient() *http.Client {
+	n.httpOnce.Do(func() {
+		n.http = &http.Client{Timeout: n.Requests.Timeout, CheckRedirect: checkRedirect}
+	})
+	return n.http
+}
+
+// fetch queries the data source according to the schedule. The first sample is taken FetchAhead before execution
+// timestamp, and the following ones are spaced by the data filter delay. Failed samples are skipped.
+func (n *Node) fetch(ctx context.Context, event *contracts.IOrakuruCoreRequested, source *DataSource, executionTime time.Time) (string, error) {
+	id := hexutil.Encode(event.RequestId[:])
+	start := executionTime.Add(-n.Requests.Schedule.FetchAhead)
+	if n.Requests.Schedule.Prewarm > 0 {
+		err := sleepUntil(ctx, start.Add(-n.Requests.Schedule.Prewarm))
+		if err != nil {
+			return "", err
+		}
+		n.prewarm(ctx, source)
+	}
+
+	samples := n.Requests.DataFilter.Samples
+	if samples < 1 {
+		samples = 1
+	}
+	var results []string
+	var lastErr error
+	for i := 0; i < samples; i++ {
+		err := sleepUntil(ctx, start.Add(time.Duration(i)*n.Requests.DataFilter.Delay))
+		if err != nil {
+			return "", err
+		}
+		log.Trace().Str("id", id).Int("sample", i).Msg("executing request")
+		resp, err := n.executeRequest(ctx, source, event.Selector)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("id", id).Int("sample", i).Msg("sample could not be fetched")
+			lastErr = err
+			continue
+		}
+		results = append(results, resp)
+	}
+	if len(results) == 0 {
+		return "", lastErr
+	}
+	if distinct := distinctSamples(results); distinct > 1 {
+		// Data source returns different values within a short time, it might be flaky or random
+		log.Warn().Str("id", id).Strs("samples", results).Int("distinct", distinct).Msg("samples of the data source disagree")
+		monitoring.DisagreeingSamplesCounter.Inc()
+	}
+	return reduceSamples(results, event.AggrType, n.Requests.DataFilter.OnlyNumbers)
+}
+
+// prewarm opens connection to the data source, so that the actual request doesn't wait for DNS and TLS handshakes.
+// HEAD request is used, its response is ignored.
+func (n *Node) prewarm(ctx context.Context, source *DataSource) {
+	unwrapped, err := n.unwrapDataSourceSecrets(source)
+	if err != nil {
+		return
+	}
+	r, err := http.NewRequestWithContext(unwrapped.requestContext(ctx), http.MethodHead, unwrapped.URL, nil)
+	if err != nil {
+		return
+	}
+	resp, err := n.httpClient().Do(r)
+	if err != nil {
+		log.Debug().Err(err).Msg("could not prewarm connection to the data source")
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
@@ -1,33 +1,61 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/orakurudata/crystal-ball/configuration"
 	"github.com/orakurudata/crystal-ball/contracts"
 	"github.com/rs/zerolog/log"
 	"math"
 	"math/big"
 	"net/http"
 	"os"
+	"time"
 )
 
 const (
-	BaseScore       = 100
-	TimeBonus       = 50.0
-	ExecutionWindow = 60
-	BlockTime       = 3
+	BaseScore = 100
+	TimeBonus = 50.0
+)
 
-	Decay = 1.0 / float64(ExecutionWindow-BlockTime)
+var (
+	// ExecutionWindow and BlockTime are in seconds, they are taken from the chain profile
+	ExecutionWindow uint64 = 60
+	BlockTime       uint64 = 3
 )
 
+// loadChain reads chain section of web3.yml, an empty path means no overrides
+func loadChain(web3Config string) (*configuration.Chain, error) {
+	if web3Config == "" {
+		return &configuration.Chain{}, nil
+	}
+	f, err := os.Open(web3Config)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return configuration.ParseChain(f)
+}
+
+// seconds rounds d up to whole seconds, at least one, so that sub-second block times are not scored as zero
+func seconds(d time.Duration) uint64 {
+	s := uint64((d + time.Second - 1) / time.Second)
+	if s < 1 {
+		return 1
+	}
+	return s
+}
+
 func Score(delay uint64) uint64 {
+	decay := 1.0 / float64(ExecutionWindow-BlockTime)
 	return uint64(
 		math.Min(
 			50.0,
-			TimeBonus*(1.0-(Decay*float64(delay-BlockTime))))) + BaseScore
+			TimeBonus*(1.0-(decay*float64(delay-BlockTime))))) + BaseScore
 }
 
 type Leaderboard []LeaderboardEntry
@@ -42,6 +70,8 @@ func main() {
 	coreAddress := flag.String("core", "", "address of orakuru core")
 	web3URL := flag.String("url", "", "web3 endpoint url")
 	httpAddr := flag.String("http", "", "http bind address")
+	chainName := flag.String("chain", "", "chain profile name, detected by chain id if empty")
+	web3Config := flag.String("config", "", "web3.yml of the node, its chain section overrides the chain profile")
 	flag.Parse()
 
 	if *coreAddress == "" || *web3URL == "" || *httpAddr == "" {
@@ -53,6 +83,23 @@ func main() {
 	if err != nil {
 		log.Fatal().Err(err).Caller().Msg("cannot connect to web3")
 	}
+	chainID, err := client.ChainID(context.Background())
+	if err != nil {
+		log.Fatal().Err(err).Caller().Msg("cannot get chain id")
+	}
+	configured, err := loadChain(*web3Config)
+	if err != nil {
+		log.Fatal().Err(err).Caller().Msg("cannot load chain configuration")
+	}
+	if *chainName != "" {
+		configured.Name = *chainName
+	}
+	chain, err := configuration.ResolveChain(*configured, chainID)
+	if err != nil {
+		log.Fatal().Err(err).Caller().Msg("cannot resolve chain profile")
+	}
+	ExecutionWindow = seconds(chain.ExecutionWindow)
+	BlockTime = seconds(chain.BlockTime)
 	core, err := contracts.NewIOrakuruCore(common.HexToAddress(*coreAddress), client)
 	if err != nil {
 		log.Fatal().Err(err).Caller().Msg("cannot find core contract")
@@ -2,31 +2,26 @@ package main
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"errors"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/orakurudata/crystal-ball/configuration"
 	"github.com/orakurudata/crystal-ball/contracts"
+	"github.com/orakurudata/crystal-ball/endpoints"
+	"github.com/orakurudata/crystal-ball/txmanager"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"math/big"
 	"os"
 	"path"
 	"strings"
-	"sync"
 	"time"
 )
 
 var (
-	MainnetID = big.NewInt(56)
-	TestnetID = big.NewInt(97)
-
-	ChainID = big.NewInt(0)
-
-	FulfillMutex = sync.Mutex{}
+	ErrNoCoreAddress = errors.New("neither core address nor address registry is configured")
 )
 
 func getenv(env, def string) string {
@@ -88,21 +83,21 @@ func sleepUntil(t time.Time) {
 	time.Sleep(time.Until(t))
 }
 
-func fulfillEvent(core *contracts.IOrakuruCore, event *contracts.IOrakuruCoreRequested, key *ecdsa.PrivateKey) {
-	fulfillmentTime := time.Unix(event.ExecutionTimestamp.Int64(), 0).Add(65 * time.Second)
+// FulfillmentDelay is added to the execution window, so that fulfillment doesn't race with late results
+const FulfillmentDelay = 5 * time.Second
+
+func fulfillEvent(core *contracts.IOrakuruCore, event *contracts.IOrakuruCoreRequested, transactor *txmanager.Manager, chain *configuration.Chain) {
+	fulfillmentTime := time.Unix(event.ExecutionTimestamp.Int64(), 0).Add(chain.ExecutionWindow + FulfillmentDelay)
 	sleepUntil(fulfillmentTime)
-	k, err := bind.NewKeyedTransactorWithChainID(key, ChainID)
-	if err != nil {
-		log.Error().Err(err).Msg("could not create transactor")
-		return
+	fulfill := func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return core.FulfillRequest(opts, event.RequestId)
 	}
-	FulfillMutex.Lock()
-	tx, err := core.FulfillRequest(k, event.RequestId)
-	FulfillMutex.Unlock()
+	// Fulfillment has no deadline, so the transaction is sped up until it gets mined
+	tx, err := transactor.Send(context.Background(), time.Time{}, fulfill)
 	if err != nil {
 		log.Warn().Err(err).Caller().Msg("could not fulfill request the first time, retying in a random amount of seconds")
 		time.Sleep(5 * time.Second)
-		tx, err = core.FulfillRequest(k, event.RequestId)
+		tx, err = transactor.Send(context.Background(), time.Time{}, fulfill)
 		if err != nil {
 			log.Error().Err(err).Caller().Msg("could not fulfill request the second time as well")
 			return
@@ -111,27 +106,49 @@ func fulfillEvent(core *contracts.IOrakuruCore, event *contracts.IOrakuruCoreReq
 	log.Info().Str("id", hexutil.Encode(event.RequestId[:])).Str("tx", tx.Hash().Hex()).Msg("request is fulfilled")
 }
 
+// resolveCore returns the core address, which is taken from the address registry when it is configured
+func resolveCore(web3 *configuration.Web3, c *endpoints.Pool) (common.Address, error) {
+	switch {
+	case web3.AddressRegistry != "":
+		registry, err := contracts.NewIAddressRegistry(common.HexToAddress(web3.AddressRegistry), c)
+		if err != nil {
+			return common.Address{}, err
+		}
+		return registry.GetOrakuruCoreAddr(nil)
+	case web3.OrakuruCore != "":
+		return common.HexToAddress(web3.OrakuruCore), nil
+	default:
+		return common.Address{}, ErrNoCoreAddress
+	}
+}
+
 func runExecutor(web3 *configuration.Web3) error {
 	address := crypto.PubkeyToAddress(web3.PrivateKey.PublicKey)
 	log.Info().Str("wallet", address.String()).Msg("request-fulfiller is starting")
-	c, err := ethclient.Dial(web3.URL)
+	c, err := endpoints.Dial(context.Background(), web3.URLs)
 	if err != nil {
 		return err
 	}
+	go c.Run(context.Background())
 	chainID, err := c.ChainID(context.Background())
 	if err != nil {
 		return err
 	}
-	switch {
-	case chainID.Cmp(MainnetID) == 0:
-		log.Info().Msg("mainnet endpoint detected")
-	case chainID.Cmp(TestnetID) == 0:
-		log.Info().Msg("testnet endpoint detected")
-	default:
-		log.Warn().Msg("endpoint network is unknown")
+	chain, err := web3.ResolveChain(chainID)
+	if err != nil {
+		return err
+	}
+	if chain.Name == configuration.UnknownChain.Name {
+		log.Warn().Uint64("chain_id", chain.ChainID).Msg("endpoint network is unknown, using default chain profile")
+	} else {
+		log.Info().Str("chain", chain.Name).Uint64("chain_id", chain.ChainID).Msg("endpoint network detected")
+	}
+	transactor := txmanager.NewManager(c, web3.PrivateKey, chainID, web3.Gas)
+	coreAddress, err := resolveCore(web3, c)
+	if err != nil {
+		return err
 	}
-	ChainID = chainID
-	coreAddress := common.HexToAddress(web3.OrakuruCore)
+	log.Info().Str("core", coreAddress.String()).Msg("using core contract")
 	core, err := contracts.NewIOrakuruCore(coreAddress, c)
 	if err != nil {
 		return err
@@ -166,7 +183,7 @@ outer:
 					}
 					requests[event.RequestId] = true
 					log.Trace().Str("id", hexutil.Encode(event.RequestId[:])).Msg("received an event")
-					fulfillEvent(core, event, web3.PrivateKey)
+					fulfillEvent(core, event, transactor, chain)
 					delete(requests, event.RequestId)
 				}()
 			case err := <-sub.Err():
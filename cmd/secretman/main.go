@@ -2,20 +2,41 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/orakurudata/crystal-ball/configuration"
 	"github.com/orakurudata/crystal-ball/secrets"
 	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoSeed        = errors.New("seed is not provided, use -seed, -seed-file or -config")
+	ErrInvalidSeed   = errors.New("seed has to be 32 bytes long")
+	ErrNoPubkey      = errors.New("pubkey is not provided, use -pubkey or -pubkey-file")
+	ErrInvalidPubkey = errors.New("pubkey has to be 32 bytes long")
 )
 
 func main() {
 	generateMode := flag.Bool("generate", false, "generate a new key")
 	encryptMode := flag.Bool("encrypt", false, "encrypt a message")
-	decryptMode := flag.Bool("decrypt", false, "decrypt a message")
-	message := flag.String("message", "", "message to encrypt")
-	target := flag.String("pubkey", "", "recipient pubkey")
+	decryptMode := flag.Bool("decrypt", false, "decrypt all secrets in a message")
+	inspectMode := flag.Bool("inspect", false, "show details of all secrets in a message")
+	deriveMode := flag.Bool("derive", false, "derive pubkey from a seed")
+	message := flag.String("message", "", "message to encrypt, or text with secrets to decrypt or inspect")
+	target := flag.String("pubkey", "", "recipient pubkey, or a comma separated list of pubkeys")
+	targetFile := flag.String("pubkey-file", "", "file containing recipient pubkeys, one per line")
+	envelope := flag.Bool("envelope", false, "encrypt into a multi-recipient envelope even for a single pubkey")
+	host := flag.String("host", "", "host the secret may only be sent to, e.g. api.example.com or *.example.com")
+	seed := flag.String("seed", "", "base64 encoded seed")
+	seedFile := flag.String("seed-file", "", "file containing base64 encoded seed")
+	config := flag.String("config", "", "requests.yml to take secret_key from")
+	out := flag.String("out", "", "file to write generated seed to, pubkey is written to the same file with .pub extension")
 	flag.Parse()
-	if !*generateMode && !*encryptMode && !*decryptMode {
+	if !*generateMode && !*encryptMode && !*decryptMode && !*inspectMode && !*deriveMode {
 		fmt.Println("specify operation mode")
 		flag.PrintDefaults()
 		os.Exit(1)
@@ -31,14 +52,38 @@ func main() {
 			fmt.Println("conversion error:", err)
 			os.Exit(2)
 		}
-		fmt.Println("Seed:", base64.StdEncoding.EncodeToString(seed))
+		if *out != "" {
+			err = writeKeys(*out, seed, public)
+			if err != nil {
+				fmt.Println("could not write keys:", err)
+				os.Exit(2)
+			}
+			fmt.Println("Seed written to:", *out)
+			fmt.Println("Pubkey written to:", *out+".pub")
+		} else {
+			fmt.Println("Seed:", base64.StdEncoding.EncodeToString(seed))
+		}
 		fmt.Println("Pubkey:", base64.StdEncoding.EncodeToString(public))
 	} else if *encryptMode {
-		targetKey, err := base64.StdEncoding.DecodeString(*target)
+		targets, err := loadPubkeys(*target, *targetFile)
 		if err != nil {
-			fmt.Println("pubkey decode failed:", err)
+			fmt.Println("could not load pubkeys:", err)
 			os.Exit(2)
 		}
+		if *envelope || *host != "" || len(targets) > 1 {
+			var encoded string
+			if *host != "" {
+				encoded, err = secrets.EncryptBoundEnvelope(targets, *host, *message)
+			} else {
+				encoded, err = secrets.EncryptEnvelope(targets, *message)
+			}
+			if err != nil {
+				fmt.Println("data encryption failed:", err)
+				os.Exit(2)
+			}
+			fmt.Println(encoded)
+			return
+		}
 		key, err := secrets.GenerateKey()
 		if err != nil {
 			fmt.Println("failed to generate ephemeral key:", err)
@@ -49,11 +94,243 @@ func main() {
 			fmt.Println("conversion failed:", err)
 			os.Exit(2)
 		}
-		encryptedData, err := secrets.Encrypt(key, targetKey, *message)
+		encryptedData, err := secrets.Encrypt(key, targets[0], *message)
 		if err != nil {
 			fmt.Println("data encryption failed:", err)
 			os.Exit(2)
 		}
 		fmt.Println(secrets.Encode(pub, encryptedData))
+	} else if *decryptMode {
+		keys, err := loadKeys(*seed, *seedFile, *config)
+		if err != nil {
+			fmt.Println("could not load seed:", err)
+			os.Exit(2)
+		}
+		blobs := secrets.BlobRegexp.FindAllString(*message, -1)
+		if len(blobs) == 0 {
+			fmt.Println("message contains no secrets")
+			os.Exit(2)
+		}
+		for _, blob := range blobs {
+			// Retired keys are still used, so that old secrets can be decrypted and encrypted again
+			value, _, err := keys.Open(blob, time.Time{})
+			if err != nil {
+				fmt.Println("secret decryption failed, it was encrypted for another key or corrupted:", err)
+				os.Exit(2)
+			}
+			fmt.Println(value)
+		}
+	} else if *inspectMode {
+		inspect(*message, *seed, *seedFile, *config)
+	} else if *deriveMode {
+		keys, err := loadKeys(*seed, *seedFile, *config)
+		if err != nil {
+			fmt.Println("could not load seed:", err)
+			os.Exit(2)
+		}
+		for _, key := range keys {
+			fmt.Println("Pubkey:", base64.StdEncoding.EncodeToString(key.Public))
+			fmt.Println("  Key ID:", hex.EncodeToString(key.ID))
+			if !key.RetireAt.IsZero() {
+				fmt.Println("  Retire at:", key.RetireAt.Format(time.RFC3339))
+			}
+		}
+	}
+}
+
+// inspect prints details of every secret in message. Regular secrets don't contain the recipient key,
+// so it can only be checked against seeds, if they are provided. Envelopes list key IDs of their recipients.
+func inspect(message, seed, seedFile, config string) {
+	var keys secrets.Keyring
+	if seed != "" || seedFile != "" || config != "" {
+		var err error
+		keys, err = loadKeys(seed, seedFile, config)
+		if err != nil {
+			fmt.Println("could not load seed:", err)
+			os.Exit(2)
+		}
+	}
+	blobs := secrets.BlobRegexp.FindAllString(message, -1)
+	if len(blobs) == 0 {
+		fmt.Println("message contains no secrets")
+		os.Exit(2)
+	}
+	for i, blob := range blobs {
+		fmt.Printf("Secret #%d\n", i+1)
+		if strings.HasPrefix(blob, "$$"+secrets.EnvelopePrefix+":") {
+			inspectEnvelope(blob, keys)
+			continue
+		}
+		point, data, err := secrets.Decode(blob)
+		if err != nil {
+			fmt.Println("  Invalid encoding:", err)
+			continue
+		}
+		fmt.Println("  Sender pubkey:", base64.StdEncoding.EncodeToString(point))
+		fmt.Println("  Ciphertext size:", len(data))
+		for _, key := range keys {
+			if _, err = secrets.Decrypt(key.Seed, point, data); err == nil {
+				fmt.Println("  Encrypted for pubkey:", base64.StdEncoding.EncodeToString(key.Public))
+				break
+			}
+		}
+		if err != nil {
+			fmt.Println("  Not encrypted for any of the provided keys")
+		}
+	}
+}
+
+func inspectEnvelope(blob string, keys secrets.Keyring) {
+	data, err := base64.StdEncoding.DecodeString(blob[len(secrets.EnvelopePrefix)+3 : len(blob)-2])
+	if err != nil {
+		fmt.Println("  Invalid encoding:", err)
+		return
+	}
+	e, err := secrets.ParseEnvelope(data)
+	if err != nil {
+		fmt.Println("  Invalid envelope:", err)
+		return
+	}
+	fmt.Println("  Envelope version:", e.Version)
+	if e.Host != "" {
+		fmt.Println("  Bound to host:", e.Host)
+	}
+	fmt.Println("  Sender pubkey:", base64.StdEncoding.EncodeToString(e.Sender))
+	fmt.Println("  Ciphertext size:", len(e.Ciphertext))
+	fmt.Println("  Recipients:")
+	for _, r := range e.Recipients {
+		fmt.Println("   ", hex.EncodeToString(r.KeyID))
+	}
+	if keys == nil {
+		return
+	}
+	for _, key := range keys {
+		if _, err = e.Open(key.Seed); err == nil {
+			fmt.Println("  Encrypted for pubkey:", base64.StdEncoding.EncodeToString(key.Public))
+			fmt.Println("  Key ID:", hex.EncodeToString(key.ID))
+			return
+		}
+	}
+	fmt.Println("  Not encrypted for any of the provided keys")
+}
+
+// loadPubkeys reads recipient pubkeys from the comma separated flag value and a file with one pubkey per line.
+// Oracle pubkeys are not published on-chain, so they have to be collected from oracle operators.
+func loadPubkeys(list, file string) ([]secrets.PublicKey, error) {
+	var encoded []string
+	if list != "" {
+		encoded = strings.Split(list, ",")
+	}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, strings.Split(string(data), "\n")...)
+	}
+	var out []secrets.PublicKey
+	for _, v := range encoded {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.HasPrefix(v, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != 32 {
+			return nil, ErrInvalidPubkey
+		}
+		out = append(out, key)
+	}
+	if len(out) == 0 {
+		return nil, ErrNoPubkey
+	}
+	return out, nil
+}
+
+// loadKeys reads seed from the flag value or a file, or all keys of requests.yml including retired ones
+func loadKeys(seed, seedFile, config string) (secrets.Keyring, error) {
+	var keys secrets.Keyring
+	add := func(seed []byte, retireAt time.Time) error {
+		if len(seed) != 32 {
+			return ErrInvalidSeed
+		}
+		key, err := secrets.NewKey(seed, retireAt)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	}
+	switch {
+	case seed != "":
+		decoded, err := base64.StdEncoding.DecodeString(seed)
+		if err != nil {
+			return nil, err
+		}
+		if err = add(decoded, time.Time{}); err != nil {
+			return nil, err
+		}
+	case seedFile != "":
+		data, err := os.ReadFile(seedFile)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, err
+		}
+		if err = add(decoded, time.Time{}); err != nil {
+			return nil, err
+		}
+	case config != "":
+		f, err := os.Open(config)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		requests, err := configuration.ParseRequests(f)
+		if err != nil {
+			return nil, err
+		}
+		if requests.RawSecretKey != "" {
+			err = add(requests.SecretKey, time.Time{})
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, k := range requests.SecretKeys {
+			err = add(k.Key, k.RetireAt)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoSeed
+	}
+	return keys, nil
+}
+
+// writeKeys writes seed readable only by the owner, and pubkey next to it. Existing files are never overwritten.
+func writeKeys(path string, seed secrets.Seed, public secrets.PublicKey) error {
+	err := writeFile(path, base64.StdEncoding.EncodeToString(seed), 0600)
+	if err != nil {
+		return err
+	}
+	return writeFile(path+".pub", base64.StdEncoding.EncodeToString(public), 0644)
+}
+
+func writeFile(path, content string, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(content + "\n")
+	if err != nil {
+		_ = f.Close()
+		return err
 	}
+	return f.Close()
 }
@@ -0,0 +1,125 @@
+package configuration
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"time"
+)
+
+// Chain describes network specific settings. Profiles of known networks are built in, see Chains,
+// and any of their fields can be overridden in web3.yml.
+type Chain struct {
+	// Name selects one of built-in profiles. It can be omitted, in which case profile is chosen by chain ID
+	Name string `yaml:"name"`
+	// ChainID contains expected chain ID of the network. Node refuses to start if endpoint reports another one
+	ChainID uint64 `yaml:"chain_id"`
+	// RawBlockTime contains time.Duration encoded expected time between blocks
+	RawBlockTime string `yaml:"block_time"`
+	// BlockTime contains parsed RawBlockTime
+	BlockTime time.Duration `yaml:"-"`
+	// RawExecutionWindow contains time.Duration encoded time after execution timestamp during which results are accepted
+	RawExecutionWindow string `yaml:"execution_window"`
+	// ExecutionWindow contains parsed RawExecutionWindow
+	ExecutionWindow time.Duration `yaml:"-"`
+	// CoreAddresses contains known addresses of the core contract
+	CoreAddresses []string `yaml:"core_addresses"`
+	// Gas contains default gas configuration, which is used when web3.yml has no gas section
+	Gas Gas `yaml:"gas"`
+}
+
+// Chains contains built-in chain profiles
+var Chains = map[string]Chain{
+	"bsc": {
+		Name:            "bsc",
+		ChainID:         56,
+		BlockTime:       3 * time.Second,
+		ExecutionWindow: 1 * time.Minute,
+		Gas:             Gas{Strategy: GasStrategyOracle},
+	},
+	"bsc-testnet": {
+		Name:            "bsc-testnet",
+		ChainID:         97,
+		BlockTime:       3 * time.Second,
+		ExecutionWindow: 1 * time.Minute,
+		Gas:             Gas{Strategy: GasStrategyOracle},
+	},
+	"dev": {
+		Name:            "dev",
+		ChainID:         1337,
+		BlockTime:       1 * time.Second,
+		ExecutionWindow: 1 * time.Minute,
+		Gas:             Gas{Strategy: GasStrategyFixed, RawPrice: "1"},
+	},
+}
+
+// UnknownChain is used for networks that have no built-in profile
+var UnknownChain = Chain{
+	Name:            "unknown",
+	BlockTime:       3 * time.Second,
+	ExecutionWindow: 1 * time.Minute,
+	Gas:             Gas{Strategy: GasStrategyOracle},
+}
+
+// IsKnownCore reports whether address is one of CoreAddresses. Any address is accepted if there are none.
+func (c *Chain) IsKnownCore(address common.Address) bool {
+	if len(c.CoreAddresses) == 0 {
+		return true
+	}
+	for _, known := range c.CoreAddresses {
+		if common.HexToAddress(known) == address {
+			return true
+		}
+	}
+	return false
+}
+
+// ChainByID returns built-in profile of the network with chainID
+func ChainByID(chainID *big.Int) (Chain, bool) {
+	for _, c := range Chains {
+		if new(big.Int).SetUint64(c.ChainID).Cmp(chainID) == 0 {
+			return c, true
+		}
+	}
+	return Chain{}, false
+}
+
+// ResolveChain merges configured chain with the built-in profile of the network with chainID.
+// Profile is chosen by name when it is configured, and by chainID otherwise.
+func ResolveChain(configured Chain, chainID *big.Int) (*Chain, error) {
+	var base Chain
+	var ok bool
+	if configured.Name != "" {
+		base, ok = Chains[configured.Name]
+		if !ok && configured.ChainID == 0 {
+			return nil, ErrUnknownChain
+		}
+	} else {
+		base, ok = ChainByID(chainID)
+	}
+	if !ok {
+		base = UnknownChain
+	}
+	if configured.Name != "" {
+		base.Name = configured.Name
+	}
+	if configured.ChainID != 0 {
+		base.ChainID = configured.ChainID
+	}
+	if base.ChainID != 0 && new(big.Int).SetUint64(base.ChainID).Cmp(chainID) != 0 {
+		return nil, ErrChainMismatch
+	}
+	base.ChainID = chainID.Uint64()
+	if configured.BlockTime != 0 {
+		base.BlockTime = configured.BlockTime
+	}
+	if configured.ExecutionWindow != 0 {
+		base.ExecutionWindow = configured.ExecutionWindow
+	}
+	if len(configured.CoreAddresses) != 0 {
+		base.CoreAddresses = configured.CoreAddresses
+	}
+	if configured.Gas != (Gas{}) {
+		base.Gas = configured.Gas
+	}
+	return &base, nil
+}
@@ -0,0 +1,61 @@
+package configuration
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveChain(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured Chain
+		chainID    int64
+		want       Chain
+		wantErr    error
+	}{
+		{"test profile detected by chain id", Chain{}, 56, Chains["bsc"], nil},
+		{"test profile chosen by name", Chain{Name: "bsc-testnet"}, 97, Chains["bsc-testnet"], nil},
+		{"test name of another network", Chain{Name: "bsc"}, 97, Chain{}, ErrChainMismatch},
+		{"test chain id of another network", Chain{ChainID: 56}, 97, Chain{}, ErrChainMismatch},
+		{"test unknown name", Chain{Name: "eth"}, 1, Chain{}, ErrUnknownChain},
+		{"test unknown network", Chain{}, 1, Chain{Name: UnknownChain.Name, ChainID: 1, BlockTime: UnknownChain.BlockTime,
+			ExecutionWindow: UnknownChain.ExecutionWindow, Gas: UnknownChain.Gas}, nil},
+		{"test unknown name with chain id", Chain{Name: "eth", ChainID: 1}, 1, Chain{Name: "eth", ChainID: 1,
+			BlockTime: UnknownChain.BlockTime, ExecutionWindow: UnknownChain.ExecutionWindow, Gas: UnknownChain.Gas}, nil},
+		{"test overrides", Chain{BlockTime: 500 * time.Millisecond, ExecutionWindow: time.Minute / 2, CoreAddresses: []string{"0x01"},
+			Gas: Gas{Strategy: GasStrategyFixed}}, 56, Chain{Name: "bsc", ChainID: 56, BlockTime: 500 * time.Millisecond,
+			ExecutionWindow: time.Minute / 2, CoreAddresses: []string{"0x01"}, Gas: Gas{Strategy: GasStrategyFixed}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveChain(tt.configured, big.NewInt(tt.chainID))
+			if err != tt.wantErr {
+				t.Fatalf("ResolveChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Name != tt.want.Name || got.ChainID != tt.want.ChainID || got.BlockTime != tt.want.BlockTime ||
+				got.ExecutionWindow != tt.want.ExecutionWindow || got.Gas != tt.want.Gas ||
+				strings.Join(got.CoreAddresses, ",") != strings.Join(tt.want.CoreAddresses, ",") {
+				t.Fatalf("ResolveChain() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseChain(t *testing.T) {
+	chain, err := ParseChain(strings.NewReader("private_key: \"env:KEY\"\nchain:\n  name: bsc\n  block_time: \"500ms\"\n"))
+	if err != nil {
+		t.Fatalf("ParseChain returned an error: %v", err)
+	}
+	if chain.Name != "bsc" || chain.BlockTime != 500*time.Millisecond {
+		t.Fatalf("wrong chain parsed: %+v", *chain)
+	}
+	chain, err = ParseChain(strings.NewReader(""))
+	if err != nil || chain.Name != "" {
+		t.Fatalf("empty configuration has to have no overrides, got = %+v, err = %v", chain, err)
+	}
+}
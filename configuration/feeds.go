@@ -25,8 +25,8 @@ type Source struct {
 
 // Parser describes how response from HTTP data source should be processed
 type Parser struct {
-	// Type contains type of parser.
-	// Currently, this value only takes "json"
+	// Type contains type of parser, which is a name of a selector engine.
+	// "json" parser takes paths like "[bitcoin][usd]"
 	Type string `yaml:"type"`
 	// Path contains a path that will be used to extract data from the response
 	Path string `yaml:"path"`
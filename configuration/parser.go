@@ -1,12 +1,18 @@
 package configuration
 
 import (
+	"crypto/ecdsa"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/orakurudata/crystal-ball/executor/selector"
+	"github.com/shopspring/decimal"
 	"gopkg.in/yaml.v3"
 	"io"
+	"math/big"
+	"os"
 	"strings"
 	"time"
 )
@@ -19,6 +25,17 @@ var (
 	ErrInvalidSourceMethod          = errors.New("invalid source method")
 	ErrInvalidParserType            = errors.New("invalid parser type")
 	ErrInvalidFilterMode            = errors.New("invalid filter mode")
+	ErrInvalidGasStrategy           = errors.New("invalid gas strategy")
+	ErrInvalidGasPrice              = errors.New("invalid gas price")
+	ErrInvalidGasMultiplier         = errors.New("invalid gas multiplier")
+	ErrInvalidEventSource           = errors.New("invalid event source")
+	ErrNoEndpoints                  = errors.New("no endpoints")
+	ErrUnknownChain                 = errors.New("unknown chain")
+	ErrInvalidDataFilter            = errors.New("invalid data filter")
+	ErrInvalidSchedule              = errors.New("invalid schedule")
+	ErrChainMismatch                = errors.New("endpoint chain id does not match configured chain")
+	ErrInvalidSecretKey             = errors.New("secret key has to be base64 of 32 bytes")
+	ErrAmbiguousPrivateKey          = errors.New("either private_key or keystore has to be set")
 )
 
 // ParseRequests takes Reader, and uses yaml library to decode file into Requests struct
@@ -41,15 +58,82 @@ func ParseRequests(file io.Reader) (*Requests, error) {
 		return nil, ErrInvalidFilterMode
 	}
 	if r.RawSecretKey != "" {
-		r.SecretKey, err = base64.StdEncoding.DecodeString(r.RawSecretKey)
+		secretKey, err := ResolveSecret(r.RawSecretKey)
+		if err != nil {
+			return nil, err
+		}
+		r.SecretKey, _ = base64.StdEncoding.DecodeString(secretKey)
+	}
+	for i := range r.SecretKeys {
+		err = parseSecretKey(&r.SecretKeys[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = parseDataFilter(&r.DataFilter)
+	if err != nil {
+		return nil, err
+	}
+	err = parseSchedule(&r.Schedule)
+	if err != nil {
+		return nil, err
 	}
-	//r.DataFilter.Delay, err = time.ParseDuration(r.DataFilter.RawDelay)
-	//if err != nil {
-	//	return nil, err
-	//}
 	return r, nil
 }
 
+func parseSecretKey(k *SecretKey) error {
+	key, err := ResolveSecret(k.RawKey)
+	if err != nil {
+		return err
+	}
+	k.Key, err = base64.StdEncoding.DecodeString(key)
+	if err != nil || len(k.Key) != 32 {
+		return ErrInvalidSecretKey
+	}
+	if k.RawRetireAt == "" {
+		return nil
+	}
+	k.RetireAt, err = time.Parse(time.RFC3339, k.RawRetireAt)
+	if err != nil {
+		k.RetireAt, err = time.Parse("2006-01-02", k.RawRetireAt)
+	}
+	return err
+}
+
+func parseDataFilter(f *DataFilter) error {
+	var err error
+	if f.Samples == 0 {
+		f.Samples = 1
+	}
+	if f.Samples < 0 {
+		return ErrInvalidDataFilter
+	}
+	if f.RawDelay != "" {
+		f.Delay, err = time.ParseDuration(f.RawDelay)
+	}
+	return err
+}
+
+func parseSchedule(s *Schedule) error {
+	var err error
+	if s.RawFetchAhead != "" {
+		s.FetchAhead, err = time.ParseDuration(s.RawFetchAhead)
+		if err != nil {
+			return err
+		}
+	}
+	if s.RawPrewarm != "" {
+		s.Prewarm, err = time.ParseDuration(s.RawPrewarm)
+		if err != nil {
+			return err
+		}
+	}
+	if s.FetchAhead < 0 || s.Prewarm < 0 {
+		return ErrInvalidSchedule
+	}
+	return nil
+}
+
 func ParseWeb3(file io.Reader) (*Web3, error) {
 	dec := yaml.NewDecoder(file)
 	dec.KnownFields(true)
@@ -58,10 +142,185 @@ func ParseWeb3(file io.Reader) (*Web3, error) {
 	if err != nil {
 		return nil, err
 	}
-	w.PrivateKey, err = crypto.HexToECDSA(w.RawPrivateKey)
+	w.PrivateKey, err = parsePrivateKey(w)
+	if err != nil {
+		return nil, err
+	}
+	w.gasConfigured = w.Gas != (Gas{})
+	err = parseGas(&w.Gas)
+	if err != nil {
+		return nil, err
+	}
+	err = parseChain(&w.Chain)
+	if err != nil {
+		return nil, err
+	}
+	err = parseEndpoints(w)
+	if err != nil {
+		return nil, err
+	}
+	err = parseEvents(&w.Events)
 	return w, err
 }
 
+// ParseChain reads only the chain section of web3.yml, so that tools without a wallet use the same network profile
+func ParseChain(file io.Reader) (*Chain, error) {
+	w := struct {
+		Chain Chain `yaml:"chain"`
+	}{}
+	err := yaml.NewDecoder(file).Decode(&w)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	err = parseChain(&w.Chain)
+	return &w.Chain, err
+}
+
+// parsePrivateKey takes the wallet key either from private_key or from the encrypted keystore
+func parsePrivateKey(w *Web3) (*ecdsa.PrivateKey, error) {
+	if w.Keystore.Path == "" {
+		key, err := ResolveSecret(w.RawPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.HexToECDSA(key)
+	}
+	if w.RawPrivateKey != "" {
+		return nil, ErrAmbiguousPrivateKey
+	}
+	data, err := os.ReadFile(w.Keystore.Path)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := ResolveSecret(w.Keystore.RawPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return key.PrivateKey, nil
+}
+
+// parseEndpoints merges URL into URLs, removing duplicates
+func parseEndpoints(w *Web3) error {
+	urls := w.URLs
+	if w.URL != "" {
+		urls = append([]string{w.URL}, urls...)
+	}
+	seen := make(map[string]bool, len(urls))
+	w.URLs = nil
+	for _, u := range urls {
+		u = strings.TrimSpace(u)
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		w.URLs = append(w.URLs, u)
+	}
+	if len(w.URLs) == 0 {
+		return ErrNoEndpoints
+	}
+	return nil
+}
+
+func parseEvents(e *Events) error {
+	var err error
+	switch e.Source {
+	case "":
+		e.Source = EventSourceAuto
+	case EventSourceAuto, EventSourceSubscribe, EventSourcePoll:
+	default:
+		return ErrInvalidEventSource
+	}
+	if e.RawPollInterval == "" {
+		// Block time of the chain is used
+		return nil
+	}
+	e.PollInterval, err = time.ParseDuration(e.RawPollInterval)
+	if err == nil && e.PollInterval <= 0 {
+		return ErrInvalidEventSource
+	}
+	return err
+}
+
+func parseChain(c *Chain) error {
+	var err error
+	if c.RawBlockTime != "" {
+		c.BlockTime, err = time.ParseDuration(c.RawBlockTime)
+		if err != nil {
+			return err
+		}
+	}
+	if c.RawExecutionWindow != "" {
+		c.ExecutionWindow, err = time.ParseDuration(c.RawExecutionWindow)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Gas != (Gas{}) {
+		return parseGas(&c.Gas)
+	}
+	return nil
+}
+
+func parseGas(g *Gas) error {
+	var err error
+	if g.Strategy == "" {
+		g.Strategy = GasStrategyOracle
+	}
+	switch g.Strategy {
+	case GasStrategyFixed:
+		if g.RawPrice == "" {
+			return ErrInvalidGasPrice
+		}
+	case GasStrategyMultiplier:
+		if g.Multiplier <= 0 {
+			return ErrInvalidGasMultiplier
+		}
+	case GasStrategyOracle:
+	default:
+		return ErrInvalidGasStrategy
+	}
+	if g.RawPrice != "" {
+		g.Price, err = parseGwei(g.RawPrice)
+		if err != nil {
+			return err
+		}
+	}
+	if g.RawMaxPrice != "" {
+		g.MaxPrice, err = parseGwei(g.RawMaxPrice)
+		if err != nil {
+			return err
+		}
+	}
+	if g.BumpPercent == 0 {
+		g.BumpPercent = 15
+	}
+	// Nodes reject replacement transactions unless price is increased by at least 10%
+	if g.BumpPercent < 10 {
+		return ErrInvalidGasPrice
+	}
+	if g.RawReplaceAfter == "" {
+		g.RawReplaceAfter = "10s"
+	}
+	g.ReplaceAfter, err = time.ParseDuration(g.RawReplaceAfter)
+	return err
+}
+
+// parseGwei converts decimal amount of gwei into wei
+func parseGwei(value string) (*big.Int, error) {
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		return nil, err
+	}
+	if !d.IsPositive() {
+		return nil, ErrInvalidGasPrice
+	}
+	return d.Shift(9).BigInt(), nil
+}
+
 // ParseFeeds takes Reader, and uses yaml library to decode file into Feeds struct
 func ParseFeeds(file io.Reader) (*Feeds, error) {
 	dec := yaml.NewDecoder(file)
@@ -84,7 +343,7 @@ func ValidateFeeds(feeds *Feeds) error {
 		}
 		// TODO: check that URL is valid
 		// Make sure that parser type is known
-		if v.Parser.Type != "json" {
+		if !selector.Has(v.Parser.Type) {
 			return ErrInvalidParserType
 		}
 		// TODO: make sure that parser path is valid
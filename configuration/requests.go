@@ -13,8 +13,40 @@ type Requests struct {
 	RawSecretKey string        `yaml:"secret_key"`
 	// SecretKey contains base64 of the secret key
 	SecretKey []byte `yaml:"-"`
+	// SecretKeys contains additional secret keys, which are used to decrypt secrets during key rotation
+	SecretKeys []SecretKey `yaml:"secret_keys"`
+	// RequireBoundSecrets makes node refuse secrets that are not bound to a host. It's false by default to keep
+	// existing requests working, but unbound secrets can then be sent to any host
+	RequireBoundSecrets bool `yaml:"require_bound_secrets"`
 	// DataFilter contains configuration for random data prevention filter
-	//DataFilter DataFilter `yaml:"data_filter"`
+	DataFilter DataFilter `yaml:"data_filter"`
+	// Schedule contains configuration of the time data sources are queried at
+	Schedule Schedule `yaml:"schedule"`
+}
+
+// SecretKey describes one of the node secret keys
+type SecretKey struct {
+	// RawKey contains base64 of the secret key
+	RawKey string `yaml:"key"`
+	// Key contains parsed RawKey
+	Key []byte `yaml:"-"`
+	// RawRetireAt contains RFC 3339 timestamp or date the key is no longer used after. Key is never retired if it's empty
+	RawRetireAt string `yaml:"retire_at"`
+	// RetireAt contains parsed RawRetireAt
+	RetireAt time.Time `yaml:"-"`
+}
+
+// Schedule describes when data sources are queried relative to the execution timestamp
+type Schedule struct {
+	// RawFetchAhead contains time.Duration encoded time before execution timestamp when the first sample is fetched
+	RawFetchAhead string `yaml:"fetch_ahead"`
+	// FetchAhead contains parsed RawFetchAhead
+	FetchAhead time.Duration `yaml:"-"`
+	// RawPrewarm contains time.Duration encoded time before the first sample when connection to the data source
+	// is opened. Connection is not pre-warmed if it's empty
+	RawPrewarm string `yaml:"prewarm"`
+	// Prewarm contains parsed RawPrewarm
+	Prewarm time.Duration `yaml:"-"`
 }
 
 // Filter describes URL filter
@@ -0,0 +1,110 @@
+package configuration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	SecretRefFile  = "file:"
+	SecretRefEnv   = "env:"
+	SecretRefVault = "vault:"
+)
+
+// VaultTimeout limits time of a single request to Vault
+var VaultTimeout = 10 * time.Second
+
+var (
+	ErrEmptySecret         = errors.New("secret reference resolved to an empty value")
+	ErrVaultNotConfigured  = errors.New("VAULT_ADDR and VAULT_TOKEN have to be set to use vault secrets")
+	ErrInvalidVaultRef     = errors.New("vault secret reference has to look like vault:<path>#<field>")
+	ErrVaultSecretNotFound = errors.New("vault secret does not contain the field")
+)
+
+// ResolveSecret returns value of a secret reference. Supported references are:
+//   - file:<path> reads the file, surrounding whitespace is trimmed
+//   - env:<name> reads the environment variable
+//   - vault:<path>#<field> reads field of a Vault KV secret, using VAULT_ADDR and VAULT_TOKEN
+//
+// Any other value is returned as is, so that plaintext values keep working.
+func ResolveSecret(value string) (string, error) {
+	var out string
+	switch {
+	case strings.HasPrefix(value, SecretRefFile):
+		data, err := os.ReadFile(strings.TrimPrefix(value, SecretRefFile))
+		if err != nil {
+			return "", err
+		}
+		out = strings.TrimSpace(string(data))
+	case strings.HasPrefix(value, SecretRefEnv):
+		out = os.Getenv(strings.TrimPrefix(value, SecretRefEnv))
+	case strings.HasPrefix(value, SecretRefVault):
+		var err error
+		out, err = readVaultSecret(strings.TrimPrefix(value, SecretRefVault))
+		if err != nil {
+			return "", err
+		}
+	default:
+		return value, nil
+	}
+	if out == "" {
+		return "", ErrEmptySecret
+	}
+	return out, nil
+}
+
+// readVaultSecret reads field of a secret from Vault HTTP API. Both KV version 1 and 2 responses are supported,
+// for version 2 the path has to include "data/", e.g. secret/data/crystal-ball#private_key
+func readVaultSecret(ref string) (string, error) {
+	address, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if address == "" || token == "" {
+		return "", ErrVaultNotConfigured
+	}
+	i := strings.LastIndex(ref, "#")
+	if i <= 0 || i == len(ref)-1 {
+		return "", ErrInvalidVaultRef
+	}
+	path, field := strings.Trim(ref[:i], "/"), ref[i+1:]
+	u, err := url.Parse(strings.TrimRight(address, "/") + "/v1/" + path)
+	if err != nil {
+		return "", err
+	}
+	r, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	r.Header.Set("X-Vault-Token", token)
+	resp, err := (&http.Client{Timeout: VaultTimeout}).Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+	data := body.Data
+	// KV version 2 nests the secret into data.data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok = data["metadata"]; ok {
+			data = nested
+		}
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", ErrVaultSecretNotFound
+	}
+	return value, nil
+}
@@ -0,0 +1,109 @@
+package configuration
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	_ = os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(key, old)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
+
+func TestResolveSecret(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/crystal-ball":
+			_, _ = w.Write([]byte(`{"data":{"data":{"key":"kv2"},"metadata":{"version":1}}}`))
+		case "/v1/kv/crystal-ball":
+			_, _ = w.Write([]byte(`{"data":{"key":"kv1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+	setenv(t, "VAULT_ADDR", vault.URL)
+	setenv(t, "VAULT_TOKEN", "token")
+	setenv(t, "CB_TEST_SECRET", "env")
+	setenv(t, "CB_TEST_EMPTY", "")
+	file := path.Join(t.TempDir(), "secret")
+	_ = os.WriteFile(file, []byte("file\n"), 0600)
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "plain", false},
+		{"", "", false},
+		{"file:" + file, "file", false},
+		{"file:" + file + ".missing", "", true},
+		{"env:CB_TEST_SECRET", "env", false},
+		{"env:CB_TEST_EMPTY", "", true},
+		{"vault:secret/data/crystal-ball#key", "kv2", false},
+		{"vault:kv/crystal-ball#key", "kv1", false},
+		{"vault:kv/crystal-ball#missing", "", true},
+		{"vault:kv/missing#key", "", true},
+		{"vault:kv/crystal-ball", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ResolveSecret(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	setenv(t, "VAULT_TOKEN", "")
+	if _, err := ResolveSecret("vault:kv/crystal-ball#key"); err != ErrVaultNotConfigured {
+		t.Errorf("ResolveSecret() error = %v, want %v", err, ErrVaultNotConfigured)
+	}
+}
+
+func TestParseWeb3Keystore(t *testing.T) {
+	privateKey, _ := crypto.GenerateKey()
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.ImportECDSA(privateKey, "passphrase")
+	if err != nil {
+		t.Fatalf("failed to import key: %v", err)
+	}
+	file := account.URL.Path
+	setenv(t, "CB_TEST_PASSPHRASE", "passphrase")
+
+	w, err := ParseWeb3(strings.NewReader("url: ws://localhost\nkeystore:\n  path: " + file + "\n  passphrase: env:CB_TEST_PASSPHRASE\n"))
+	if err != nil {
+		t.Fatalf("ParseWeb3() error = %v", err)
+	}
+	if crypto.PubkeyToAddress(w.PrivateKey.PublicKey) != account.Address {
+		t.Fatalf("ParseWeb3() decrypted a wrong key")
+	}
+	_, err = ParseWeb3(strings.NewReader("url: ws://localhost\nkeystore:\n  path: " + file + "\n  passphrase: wrong\n"))
+	if err == nil {
+		t.Fatalf("ParseWeb3() accepted a wrong passphrase")
+	}
+	_, err = ParseWeb3(strings.NewReader("url: ws://localhost\nprivate_key: key\nkeystore:\n  path: " + file + "\n"))
+	if err != ErrAmbiguousPrivateKey {
+		t.Fatalf("ParseWeb3() error = %v, want %v", err, ErrAmbiguousPrivateKey)
+	}
+}
@@ -1,10 +1,107 @@
 package configuration
 
-import "crypto/ecdsa"
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"time"
+)
+
+const (
+	GasStrategyFixed      = "fixed"
+	GasStrategyOracle     = "oracle"
+	GasStrategyMultiplier = "multiplier"
+)
+
+const (
+	EventSourceAuto      = "auto"
+	EventSourceSubscribe = "subscribe"
+	EventSourcePoll      = "poll"
+)
 
 type Web3 struct {
-	URL           string            `yaml:"url"`
+	URL string `yaml:"url"`
+	// URLs contains additional endpoints. After parsing it contains all endpoints, including URL
+	URLs          []string          `yaml:"urls"`
 	RawPrivateKey string            `yaml:"private_key"`
 	OrakuruCore   string            `yaml:"orakuru_core"`
 	PrivateKey    *ecdsa.PrivateKey `yaml:"-"`
+	// Keystore contains encrypted keystore to take the private key from, instead of RawPrivateKey
+	Keystore Keystore `yaml:"keystore"`
+	// AddressRegistry contains address of the address registry. When it's set, the core is resolved through it
+	AddressRegistry string `yaml:"address_registry"`
+	// Gas contains transaction fee configuration
+	Gas Gas `yaml:"gas"`
+	// Events contains configuration of the way events are received
+	Events Events `yaml:"events"`
+	// Chain contains network profile overrides, see Chain
+	Chain Chain `yaml:"chain"`
+
+	// gasConfigured is set when web3.yml has a gas section, otherwise gas defaults of the chain are used
+	gasConfigured bool
+}
+
+// Keystore describes go-ethereum encrypted keystore file
+type Keystore struct {
+	// Path contains path of the keystore JSON file
+	Path string `yaml:"path"`
+	// RawPassphrase contains passphrase of the keystore, or a secret reference to it
+	RawPassphrase string `yaml:"passphrase"`
+}
+
+// ResolveChain resolves network profile of the endpoint with chainID, and applies its defaults
+// to the configuration. It has to be called before Gas and Events are used.
+func (w *Web3) ResolveChain(chainID *big.Int) (*Chain, error) {
+	chain, err := ResolveChain(w.Chain, chainID)
+	if err != nil {
+		return nil, err
+	}
+	if !w.gasConfigured {
+		w.Gas = chain.Gas
+		err = parseGas(&w.Gas)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if w.Events.PollInterval == 0 {
+		w.Events.PollInterval = chain.BlockTime
+	}
+	if w.OrakuruCore == "" && len(chain.CoreAddresses) != 0 {
+		w.OrakuruCore = chain.CoreAddresses[0]
+	}
+	return chain, nil
+}
+
+// Events describes how the node receives contract events
+type Events struct {
+	// Source can be "subscribe" (websocket subscriptions), "poll" (periodic log queries, works over HTTP)
+	// or "auto" (subscribe if any of endpoints supports subscriptions, poll otherwise). Default is "auto"
+	Source string `yaml:"source"`
+	// RawPollInterval contains time.Duration encoded interval between log queries of "poll" source.
+	// Default is block time of the chain
+	RawPollInterval string `yaml:"poll_interval"`
+	// PollInterval contains parsed RawPollInterval
+	PollInterval time.Duration `yaml:"-"`
+}
+
+// Gas describes how gas price of transactions is chosen and when stuck transactions are replaced
+type Gas struct {
+	// Strategy contains gas price strategy.
+	// Can only take "fixed", "oracle" or "multiplier". Default is "oracle"
+	Strategy string `yaml:"strategy"`
+	// RawPrice contains gas price in gwei that is used by "fixed" strategy
+	RawPrice string `yaml:"price"`
+	// Price contains parsed RawPrice in wei
+	Price *big.Int `yaml:"-"`
+	// Multiplier is applied to the gas price suggested by the node when "multiplier" strategy is used
+	Multiplier float64 `yaml:"multiplier"`
+	// RawMaxPrice contains gas price limit in gwei, which is never exceeded, even by replacement transactions
+	RawMaxPrice string `yaml:"max_price"`
+	// MaxPrice contains parsed RawMaxPrice in wei, or nil if there is no limit
+	MaxPrice *big.Int `yaml:"-"`
+	// BumpPercent contains percentage by which gas price is increased when transaction is replaced. Default is 15
+	BumpPercent int64 `yaml:"bump_percent"`
+	// RawReplaceAfter contains time.Duration encoded time after which a transaction that is not mined is replaced
+	RawReplaceAfter string `yaml:"replace_after"`
+	// ReplaceAfter contains parsed RawReplaceAfter
+	ReplaceAfter time.Duration `yaml:"-"`
 }
@@ -5,8 +5,12 @@ import (
 	"errors"
 	_ "github.com/mattn/go-sqlite3"
 	"strconv"
+	"strings"
+	"time"
 )
 
+const requestColumns = "request_id, data_source, selector, execution_timestamp, fulfillment_timestamp, aggr_type, precision, result, tx_hash, status"
+
 type Conn struct {
 	db *sql.DB
 }
@@ -16,6 +20,8 @@ func OpenConnection(url string) (*Conn, error) {
 	if err != nil {
 		return nil, err
 	}
+	// SQLite doesn't handle concurrent writers well, and every in-memory connection is a separate database
+	db.SetMaxOpenConns(1)
 	c := &Conn{
 		db: db,
 	}
@@ -28,7 +34,7 @@ func (c *Conn) Close() error {
 }
 
 func (c *Conn) CreateSchema() error {
-	_, err := c.db.Exec("CREATE TABLE IF NOT EXISTS requests (request_id BLOB UNIQUE, data_source TEXT, selector TEXT, execution_timestamp DATETIME, fulfillment_timestamp DATETIME)")
+	_, err := c.db.Exec("CREATE TABLE IF NOT EXISTS requests (request_id BLOB UNIQUE, data_source TEXT, selector TEXT, execution_timestamp DATETIME, fulfillment_timestamp DATETIME, aggr_type INTEGER, precision INTEGER, result TEXT, tx_hash TEXT, status TEXT)")
 	if err != nil {
 		return err
 	}
@@ -36,18 +42,62 @@ func (c *Conn) CreateSchema() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.migrateRequests()
 }
 
-func (c *Conn) GetRequests() ([]*Request, error) {
-	r, err := c.db.Query("SELECT * FROM requests")
+// requestMigrations adds columns that are missing in databases created by older versions. Requests of those versions
+// are treated as received, so that they are reconciled with the core on startup.
+var requestMigrations = []struct {
+	column     string
+	definition string
+}{
+	{"aggr_type", "INTEGER NOT NULL DEFAULT 0"},
+	{"precision", "INTEGER NOT NULL DEFAULT 0"},
+	{"result", "TEXT NOT NULL DEFAULT ''"},
+	{"tx_hash", "TEXT NOT NULL DEFAULT ''"},
+	{"status", "TEXT NOT NULL DEFAULT '" + string(StatusReceived) + "'"},
+}
+
+func (c *Conn) migrateRequests() error {
+	r, err := c.db.Query("PRAGMA table_info(requests)")
 	if err != nil {
-		return nil, err
+		return err
+	}
+	columns := make(map[string]bool)
+	for r.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var def sql.NullString
+		err = r.Scan(&cid, &name, &typ, &notNull, &def, &pk)
+		if err != nil {
+			r.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	r.Close()
+	if err = r.Err(); err != nil {
+		return err
 	}
+	for _, m := range requestMigrations {
+		if columns[m.column] {
+			continue
+		}
+		_, err = c.db.Exec("ALTER TABLE requests ADD COLUMN " + m.column + " " + m.definition)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanRequests(r *sql.Rows) ([]*Request, error) {
+	defer r.Close()
 	var out []*Request
 	for r.Next() {
 		item := &Request{}
-		err = r.Scan(&item.RequestID, &item.DataSource, &item.Selector, &item.ExecutionTimestamp, &item.FulfillmentTimestamp)
+		err := r.Scan(&item.RequestID, &item.DataSource, &item.Selector, &item.ExecutionTimestamp, &item.FulfillmentTimestamp,
+			&item.AggrType, &item.Precision, &item.Result, &item.TxHash, &item.Status)
 		if err != nil {
 			return nil, err
 		}
@@ -56,16 +106,98 @@ func (c *Conn) GetRequests() ([]*Request, error) {
 	return out, r.Err()
 }
 
+func (c *Conn) GetRequests() ([]*Request, error) {
+	r, err := c.db.Query("SELECT " + requestColumns + " FROM requests")
+	if err != nil {
+		return nil, err
+	}
+	return scanRequests(r)
+}
+
+// GetUnfinishedRequests returns requests which status is not final
+func (c *Conn) GetUnfinishedRequests() ([]*Request, error) {
+	placeholders, args := finalStatusArgs()
+	r, err := c.db.Query("SELECT "+requestColumns+" FROM requests WHERE status NOT IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRequests(r)
+}
+
+// GetRequest returns a single request, or sql.ErrNoRows if it is unknown
+func (c *Conn) GetRequest(requestID []byte) (*Request, error) {
+	r, err := c.db.Query("SELECT "+requestColumns+" FROM requests WHERE request_id = ?", requestID)
+	if err != nil {
+		return nil, err
+	}
+	out, err := scanRequests(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return out[0], nil
+}
+
 func (c *Conn) AddRequest(r *Request) error {
-	_, err := c.db.Exec("INSERT INTO requests VALUES (?, ?, ?, ?, ?)", r.RequestID, r.DataSource, r.Selector, r.ExecutionTimestamp, r.FulfillmentTimestamp)
+	_, err := c.db.Exec("INSERT INTO requests ("+requestColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		r.RequestID, r.DataSource, r.Selector, r.ExecutionTimestamp, r.FulfillmentTimestamp,
+		r.AggrType, r.Precision, r.Result, r.TxHash, r.Status)
 	return err
 }
 
+// SetRequestStatus updates status of the request
+func (c *Conn) SetRequestStatus(requestID []byte, status RequestStatus) error {
+	return c.updateRequest("UPDATE requests SET status = ? WHERE request_id = ?", status, requestID)
+}
+
+// SetRequestResult stores value returned by the data source and marks the request as executed
+func (c *Conn) SetRequestResult(requestID []byte, result string) error {
+	return c.updateRequest("UPDATE requests SET result = ?, status = ? WHERE request_id = ?", result, StatusExecuted, requestID)
+}
+
+// SetRequestTransaction stores hash of the submission transaction and marks the request as submitted
+func (c *Conn) SetRequestTransaction(requestID []byte, txHash string) error {
+	return c.updateRequest("UPDATE requests SET tx_hash = ?, status = ? WHERE request_id = ?", txHash, StatusSubmitted, requestID)
+}
+
+func (c *Conn) updateRequest(query string, args ...interface{}) error {
+	res, err := c.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func (c *Conn) FulfillRequest(requestID []byte) error {
 	_, err := c.db.Exec("DELETE FROM requests WHERE request_id = ?", requestID)
 	return err
 }
 
+// DeleteRequestsBefore removes finished requests that were executed before t
+func (c *Conn) DeleteRequestsBefore(t time.Time) error {
+	placeholders, args := finalStatusArgs()
+	_, err := c.db.Exec("DELETE FROM requests WHERE execution_timestamp < ? AND status IN ("+placeholders+")",
+		append([]interface{}{t}, args...)...)
+	return err
+}
+
+func finalStatusArgs() (string, []interface{}) {
+	args := make([]interface{}, len(finalStatuses))
+	for i, status := range finalStatuses {
+		args[i] = status
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", "), args
+}
+
 func (c *Conn) GetString(key string) (string, error) {
 	r := c.db.QueryRow("SELECT value FROM kv WHERE key = ?", key)
 	v := ""
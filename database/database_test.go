@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"golang.org/x/crypto/sha3"
+	"path"
 	"testing"
 	"time"
 )
@@ -206,3 +207,138 @@ func TestConn_FulfillRequest(t *testing.T) {
 		t.Fatalf("expected 1 requests, got %v", len(reqs))
 	}
 }
+
+func TestConn_GetRequest(t *testing.T) {
+	c, err := OpenConnection("file::memory:")
+	if err != nil {
+		t.Fatalf("OpenConnection returned an error: %v", err)
+	}
+	hash := sha3.Sum256([]byte("hello"))
+	_, err = c.GetRequest(hash[:])
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetRequest failed, want = sql.ErrNoRows, got = %v", err)
+	}
+	err = c.AddRequest(&Request{
+		RequestID:          hash[:],
+		DataSource:         "Source",
+		Selector:           "Selector",
+		ExecutionTimestamp: time.Now().Add(30 * time.Minute),
+		AggrType:           2,
+		Precision:          8,
+		Status:             StatusReceived,
+	})
+	if err != nil {
+		t.Fatalf("AddRequest returned an error: %v", err)
+	}
+	req, err := c.GetRequest(hash[:])
+	if err != nil {
+		t.Fatalf("GetRequest returned an error: %v", err)
+	}
+	if req.DataSource != "Source" || req.AggrType != 2 || req.Precision != 8 || req.Status != StatusReceived {
+		t.Fatalf("GetRequest returned wrong value: %+v", req)
+	}
+}
+
+func TestConn_RequestLifecycle(t *testing.T) {
+	c, err := OpenConnection("file::memory:")
+	if err != nil {
+		t.Fatalf("OpenConnection returned an error: %v", err)
+	}
+	hash := sha3.Sum256([]byte("hello"))
+	err = c.SetRequestStatus(hash[:], StatusFailed)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("SetRequestStatus failed, want = sql.ErrNoRows, got = %v", err)
+	}
+	err = c.AddRequest(&Request{
+		RequestID:          hash[:],
+		ExecutionTimestamp: time.Now().Add(-1 * time.Hour),
+		Status:             StatusReceived,
+	})
+	if err != nil {
+		t.Fatalf("AddRequest returned an error: %v", err)
+	}
+	err = c.SetRequestResult(hash[:], "123.45")
+	if err != nil {
+		t.Fatalf("SetRequestResult returned an error: %v", err)
+	}
+	err = c.SetRequestTransaction(hash[:], "0x01")
+	if err != nil {
+		t.Fatalf("SetRequestTransaction returned an error: %v", err)
+	}
+	req, err := c.GetRequest(hash[:])
+	if err != nil {
+		t.Fatalf("GetRequest returned an error: %v", err)
+	}
+	if req.Result != "123.45" || req.TxHash != "0x01" || req.Status != StatusSubmitted {
+		t.Fatalf("GetRequest returned wrong value: %+v", req)
+	}
+	reqs, err := c.GetUnfinishedRequests()
+	if err != nil {
+		t.Fatalf("GetUnfinishedRequests returned an error: %v", err)
+	}
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 unfinished request, got %v", len(reqs))
+	}
+	err = c.SetRequestStatus(hash[:], StatusFulfilled)
+	if err != nil {
+		t.Fatalf("SetRequestStatus returned an error: %v", err)
+	}
+	reqs, err = c.GetUnfinishedRequests()
+	if err != nil {
+		t.Fatalf("GetUnfinishedRequests returned an error: %v", err)
+	}
+	if len(reqs) != 0 {
+		t.Fatalf("expected 0 unfinished requests, got %v", len(reqs))
+	}
+	err = c.DeleteRequestsBefore(time.Now())
+	if err != nil {
+		t.Fatalf("DeleteRequestsBefore returned an error: %v", err)
+	}
+	reqs, err = c.GetRequests()
+	if err != nil {
+		t.Fatalf("GetRequests returned an error: %v", err)
+	}
+	if len(reqs) != 0 {
+		t.Fatalf("expected 0 requests, got %v", len(reqs))
+	}
+}
+
+func TestOpenConnection_migration(t *testing.T) {
+	url := path.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite3", url)
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	_, err = db.Exec("CREATE TABLE requests (request_id BLOB UNIQUE, data_source TEXT, selector TEXT, execution_timestamp DATETIME, fulfillment_timestamp DATETIME)")
+	if err != nil {
+		t.Fatalf("could not create old schema: %v", err)
+	}
+	_, err = db.Exec("INSERT INTO requests VALUES (?, ?, ?, ?, ?)", []byte{1}, "https://example.com", "", time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("could not add request: %v", err)
+	}
+	_ = db.Close()
+
+	c, err := OpenConnection(url)
+	if err != nil {
+		t.Fatalf("OpenConnection returned an error: %v", err)
+	}
+	requests, err := c.GetUnfinishedRequests()
+	if err != nil {
+		t.Fatalf("GetUnfinishedRequests returned an error: %v", err)
+	}
+	if len(requests) != 1 || requests[0].Status != StatusReceived {
+		t.Fatalf("old request was not migrated, got = %+v", requests)
+	}
+	err = c.SetRequestStatus([]byte{1}, StatusSubmitted)
+	if err != nil {
+		t.Fatalf("SetRequestStatus returned an error: %v", err)
+	}
+	// Migrations are not applied twice
+	_ = c.Close()
+	c, err = OpenConnection(url)
+	if err != nil {
+		t.Fatalf("OpenConnection returned an error: %v", err)
+	}
+	_ = c.Close()
+}
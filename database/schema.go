@@ -2,12 +2,63 @@ package database
 
 import "time"
 
+// RequestStatus describes how far the node went in processing a request
+type RequestStatus string
+
+const (
+	// StatusReceived means that the request was accepted, but its data source wasn't queried yet
+	StatusReceived RequestStatus = "received"
+	// StatusExecuted means that the data source was queried and Result contains the value
+	StatusExecuted RequestStatus = "executed"
+	// StatusSubmitted means that the result was sent to the network and TxHash contains the transaction
+	StatusSubmitted RequestStatus = "submitted"
+	// StatusMined means that the submission transaction was mined successfully
+	StatusMined RequestStatus = "mined"
+	// StatusReverted means that the submission transaction was mined, but reverted
+	StatusReverted RequestStatus = "reverted"
+	// StatusDropped means that the submission transaction was never mined
+	StatusDropped RequestStatus = "dropped"
+	// StatusFulfilled means that the request is no longer pending, because enough results were submitted
+	StatusFulfilled RequestStatus = "fulfilled"
+	// StatusFailed means that the request could not be executed or submitted
+	StatusFailed RequestStatus = "failed"
+	// StatusIgnored means that the request violates security policy
+	StatusIgnored RequestStatus = "ignored"
+	// StatusExpired means that the request left the execution window before the result was submitted
+	StatusExpired RequestStatus = "expired"
+	// StatusResponded means that our oracle has already submitted a result, e.g. before the node was restarted
+	StatusResponded RequestStatus = "responded"
+	// StatusCanceled means that the request was canceled by the requester
+	StatusCanceled RequestStatus = "canceled"
+)
+
+// finalStatuses contains statuses of requests that will never be processed again
+var finalStatuses = []RequestStatus{
+	StatusMined, StatusReverted, StatusDropped, StatusFulfilled, StatusFailed, StatusIgnored, StatusExpired, StatusCanceled,
+	StatusResponded,
+}
+
+// Final reports whether a request in this status will never be processed again
+func (s RequestStatus) Final() bool {
+	for _, status := range finalStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 type Request struct {
 	RequestID            []byte
 	DataSource           string
 	Selector             string
 	ExecutionTimestamp   time.Time
 	FulfillmentTimestamp time.Time
+	AggrType             uint8
+	Precision            uint8
+	Result               string
+	TxHash               string
+	Status               RequestStatus
 }
 
 type KV struct {
@@ -0,0 +1,101 @@
+package endpoints
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ewmaWeight defines how much the latest observation affects latency and error rate
+const ewmaWeight = 0.2
+
+// Endpoint is a single Web3 endpoint of the Pool, along with its health statistics
+type Endpoint struct {
+	URL string
+
+	mutex   sync.Mutex
+	client  *ethclient.Client
+	head    uint64
+	alive   bool
+	latency time.Duration
+	// errorRate is an exponentially weighted share of failed calls, from 0 to 1
+	errorRate float64
+}
+
+// NewEndpoint creates endpoint that uses an already established connection
+func NewEndpoint(url string, c *rpc.Client) *Endpoint {
+	return &Endpoint{URL: url, client: ethclient.NewClient(c)}
+}
+
+// Name returns host of the endpoint, which is safe to log unlike the URL that might contain API keys
+func (e *Endpoint) Name() string {
+	u, err := url.Parse(e.URL)
+	if err != nil || u.Host == "" {
+		return "ipc"
+	}
+	return u.Host
+}
+
+// CanSubscribe reports whether the endpoint supports subscriptions, which HTTP endpoints don't
+func (e *Endpoint) CanSubscribe() bool {
+	return !strings.HasPrefix(e.URL, "http://") && !strings.HasPrefix(e.URL, "https://")
+}
+
+func (e *Endpoint) getClient() *ethclient.Client {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.client
+}
+
+// dial connects to the endpoint unless it is already connected
+func (e *Endpoint) dial(ctx context.Context) (*ethclient.Client, error) {
+	if c := e.getClient(); c != nil {
+		return c, nil
+	}
+	c, err := ethclient.DialContext(ctx, e.URL)
+	if err != nil {
+		return nil, err
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.client != nil {
+		c.Close()
+		return e.client, nil
+	}
+	e.client = c
+	return c, nil
+}
+
+// observe records outcome of a call to the endpoint
+func (e *Endpoint) observe(latency time.Duration, failed bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	failure := 0.0
+	if failed {
+		failure = 1
+	} else {
+		e.latency = time.Duration((1-ewmaWeight)*float64(e.latency) + ewmaWeight*float64(latency))
+	}
+	e.errorRate = (1-ewmaWeight)*e.errorRate + ewmaWeight*failure
+}
+
+// observeHead records result of a health check
+func (e *Endpoint) observeHead(head uint64, alive bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.alive = alive
+	if alive {
+		e.head = head
+	}
+}
+
+// health returns statistics of the endpoint
+func (e *Endpoint) health() (head uint64, alive bool, latency time.Duration, errorRate float64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.head, e.alive && e.client != nil, e.latency, e.errorRate
+}
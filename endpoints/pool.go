@@ -0,0 +1,364 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// HealthCheckInterval defines how often heads of all endpoints are checked
+	HealthCheckInterval = 5 * time.Second
+	// HealthCheckTimeout limits duration of a single health check
+	HealthCheckTimeout = 3 * time.Second
+	// BroadcastTimeout limits how long transactions are being sent to the slower endpoints
+	BroadcastTimeout = 10 * time.Second
+)
+
+const (
+	// MaxHeadLag is the amount of blocks endpoint can be behind the others and still be considered healthy
+	MaxHeadLag = 3
+	// MaxErrorRate is the share of failed calls after which endpoint is considered unhealthy
+	MaxErrorRate = 0.5
+	// LagPenalty is added to the endpoint score for every block it is behind the others
+	LagPenalty = 500 * time.Millisecond
+	// ErrorPenalty is multiplied by error rate and added to the endpoint score
+	ErrorPenalty = 10 * time.Second
+)
+
+var (
+	ErrNoEndpoints     = errors.New("no available endpoints")
+	ErrNoSubscriptions = errors.New("none of endpoints supports subscriptions")
+)
+
+// Pool distributes calls between several Web3 endpoints. Reads are sent to the healthiest endpoint and fail over
+// to the next one if it doesn't respond, while transactions are broadcast to all endpoints.
+// Endpoints are ranked by head lag, error rate and latency, see score.
+type Pool struct {
+	endpoints []*Endpoint
+
+	mutex sync.Mutex
+	// subscriber is the best endpoint supporting subscriptions, subscriptions are moved to it when it changes
+	subscriber    *Endpoint
+	subscriptions map[chan struct{}]struct{}
+}
+
+// Dial connects to all endpoints and checks their health. It fails only if none of them is available.
+func Dial(ctx context.Context, urls []string) (*Pool, error) {
+	var endpoints []*Endpoint
+	for _, u := range urls {
+		e := &Endpoint{URL: u}
+		_, err := e.dial(ctx)
+		if err != nil {
+			log.Warn().Err(err).Str("endpoint", e.Name()).Msg("could not connect to endpoint")
+		}
+		endpoints = append(endpoints, e)
+	}
+	return NewPool(ctx, endpoints...)
+}
+
+// NewPool checks health of endpoints and creates a pool of them. It fails only if none of them is available.
+func NewPool(ctx context.Context, endpoints ...*Endpoint) (*Pool, error) {
+	p := &Pool{endpoints: endpoints}
+	p.check(ctx)
+	for _, e := range p.endpoints {
+		if _, alive, _, _ := e.health(); alive {
+			return p, nil
+		}
+	}
+	return nil, ErrNoEndpoints
+}
+
+// Run checks health of endpoints until ctx is canceled
+func (p *Pool) Run(ctx context.Context) {
+	ticker := time.NewTicker(HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Endpoints returns all endpoints of the pool
+func (p *Pool) Endpoints() []*Endpoint {
+	return p.endpoints
+}
+
+// CanSubscribe reports whether any of endpoints supports subscriptions
+func (p *Pool) CanSubscribe() bool {
+	for _, e := range p.endpoints {
+		if e.CanSubscribe() {
+			return true
+		}
+	}
+	return false
+}
+
+// check updates heads of all endpoints, connecting to the ones that were not available before
+func (p *Pool) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *Endpoint) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+			defer cancel()
+			c, err := e.dial(ctx)
+			if err != nil {
+				e.observeHead(0, false)
+				return
+			}
+			start := time.Now()
+			head, err := c.BlockNumber(ctx)
+			e.observe(time.Since(start), err != nil)
+			e.observeHead(head, err == nil)
+			if err != nil {
+				log.Debug().Err(err).Str("endpoint", e.Name()).Msg("endpoint health check failed")
+			}
+		}(e)
+	}
+	wg.Wait()
+
+	p.updateSubscriber(p.ranked())
+}
+
+// ranked returns connected endpoints ordered from the best to the worst. Unhealthy endpoints are kept at the end,
+// so they are still tried when all of healthy ones fail.
+func (p *Pool) ranked() []*Endpoint {
+	type candidate struct {
+		endpoint *Endpoint
+		healthy  bool
+		score    time.Duration
+	}
+	var maxHead uint64
+	for _, e := range p.endpoints {
+		if head, alive, _, _ := e.health(); alive && head > maxHead {
+			maxHead = head
+		}
+	}
+	var candidates []candidate
+	for _, e := range p.endpoints {
+		head, alive, latency, errorRate := e.health()
+		if e.getClient() == nil {
+			continue
+		}
+		var lag uint64
+		if head < maxHead {
+			lag = maxHead - head
+		}
+		candidates = append(candidates, candidate{
+			endpoint: e,
+			healthy:  alive && lag <= MaxHeadLag && errorRate < MaxErrorRate,
+			score:    score(lag, latency, errorRate),
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].healthy != candidates[j].healthy {
+			return candidates[i].healthy
+		}
+		return candidates[i].score < candidates[j].score
+	})
+	out := make([]*Endpoint, len(candidates))
+	for i, c := range candidates {
+		out[i] = c.endpoint
+	}
+	return out
+}
+
+// score estimates how long it takes to get an up-to-date response from the endpoint, lower is better
+func score(lag uint64, latency time.Duration, errorRate float64) time.Duration {
+	return latency + time.Duration(lag)*LagPenalty + time.Duration(errorRate*float64(ErrorPenalty))
+}
+
+// isEndpointError reports whether err is caused by the endpoint, and the call should be retried on another one.
+// Errors returned by the node itself, like reverts or nonce errors, would be the same on any endpoint.
+func isEndpointError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil || errors.Is(err, ethereum.NotFound) {
+		return false
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		// Rate limiting is specific to the provider
+		return rpcErr.ErrorCode() == -32005
+	}
+	return true
+}
+
+// call runs fn with clients of endpoints, starting from the best one, until it succeeds or fails with a non-endpoint error
+func (p *Pool) call(ctx context.Context, fn func(c *ethclient.Client) error) error {
+	lastErr := ErrNoEndpoints
+	for _, e := range p.ranked() {
+		start := time.Now()
+		err := fn(e.getClient())
+		failed := isEndpointError(ctx, err)
+		e.observe(time.Since(start), failed)
+		if !failed {
+			return err
+		}
+		log.Debug().Err(err).Str("endpoint", e.Name()).Msg("endpoint call failed, trying another one")
+		lastErr = err
+	}
+	return lastErr
+}
+
+// SendTransaction broadcasts transaction to all endpoints. It returns as soon as any of them accepts the transaction,
+// and the error of the best endpoint if none of them does.
+func (p *Pool) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	ranked := p.ranked()
+	if len(ranked) == 0 {
+		return ErrNoEndpoints
+	}
+	type result struct {
+		rank int
+		err  error
+	}
+	// Slower endpoints receive the transaction even after the call returns
+	sendCtx, cancel := context.WithTimeout(context.Background(), BroadcastTimeout)
+	results := make(chan result, len(ranked))
+	var wg sync.WaitGroup
+	for i, e := range ranked {
+		wg.Add(1)
+		go func(rank int, e *Endpoint) {
+			defer wg.Done()
+			start := time.Now()
+			err := e.getClient().SendTransaction(sendCtx, tx)
+			e.observe(time.Since(start), isEndpointError(sendCtx, err))
+			results <- result{rank: rank, err: err}
+		}(i, e)
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+
+	errs := make([]error, len(ranked))
+	for range ranked {
+		select {
+		case r := <-results:
+			if r.err == nil {
+				return nil
+			}
+			errs[r.rank] = r.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errs[0]
+}
+
+func (p *Pool) FilterLogs(ctx context.Context, query ethereum.FilterQuery) (logs []types.Log, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		logs, err = c.FilterLogs(ctx, query)
+		return
+	})
+	return
+}
+
+func (p *Pool) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) (code []byte, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		code, err = c.CodeAt(ctx, contract, blockNumber)
+		return
+	})
+	return
+}
+
+func (p *Pool) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) (out []byte, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		out, err = c.CallContract(ctx, call, blockNumber)
+		return
+	})
+	return
+}
+
+func (p *Pool) PendingCodeAt(ctx context.Context, account common.Address) (code []byte, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		code, err = c.PendingCodeAt(ctx, account)
+		return
+	})
+	return
+}
+
+func (p *Pool) PendingNonceAt(ctx context.Context, account common.Address) (nonce uint64, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		nonce, err = c.PendingNonceAt(ctx, account)
+		return
+	})
+	return
+}
+
+func (p *Pool) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (nonce uint64, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		nonce, err = c.NonceAt(ctx, account, blockNumber)
+		return
+	})
+	return
+}
+
+func (p *Pool) SuggestGasPrice(ctx context.Context) (price *big.Int, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		price, err = c.SuggestGasPrice(ctx)
+		return
+	})
+	return
+}
+
+func (p *Pool) EstimateGas(ctx context.Context, call ethereum.CallMsg) (gas uint64, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		gas, err = c.EstimateGas(ctx, call)
+		return
+	})
+	return
+}
+
+func (p *Pool) TransactionReceipt(ctx context.Context, txHash common.Hash) (receipt *types.Receipt, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		receipt, err = c.TransactionReceipt(ctx, txHash)
+		return
+	})
+	return
+}
+
+func (p *Pool) HeaderByNumber(ctx context.Context, number *big.Int) (header *types.Header, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		header, err = c.HeaderByNumber(ctx, number)
+		return
+	})
+	return
+}
+
+func (p *Pool) BlockNumber(ctx context.Context) (number uint64, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		number, err = c.BlockNumber(ctx)
+		return
+	})
+	return
+}
+
+func (p *Pool) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (balance *big.Int, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		balance, err = c.BalanceAt(ctx, account, blockNumber)
+		return
+	})
+	return
+}
+
+func (p *Pool) ChainID(ctx context.Context) (id *big.Int, err error) {
+	err = p.call(ctx, func(c *ethclient.Client) (err error) {
+		id, err = c.ChainID(ctx)
+		return
+	})
+	return
+}
@@ -0,0 +1,112 @@
+package endpoints
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/rs/zerolog/log"
+)
+
+// SubscribeFilterLogs subscribes using the best endpoint that supports subscriptions. When another endpoint becomes
+// the best one, subscription is moved to it. Subscription fails if its endpoint fails, callers resubscribe then.
+func (p *Pool) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	inner, e, err := p.subscribe(ctx, query, ch)
+	if err != nil {
+		return nil, err
+	}
+	moved := make(chan struct{}, 1)
+	p.mutex.Lock()
+	if p.subscriptions == nil {
+		p.subscriptions = make(map[chan struct{}]struct{})
+	}
+	p.subscriptions[moved] = struct{}{}
+	p.mutex.Unlock()
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer func() {
+			p.mutex.Lock()
+			delete(p.subscriptions, moved)
+			p.mutex.Unlock()
+			inner.Unsubscribe()
+		}()
+		for {
+			select {
+			case <-moved:
+				next := p.getSubscriber()
+				if next == nil || next == e {
+					continue
+				}
+				// New subscription is created before the old one is removed, so no logs are missed while moving.
+				// Logs delivered by both are duplicated, which subscribers have to tolerate anyway.
+				ctx, cancel := context.WithTimeout(context.Background(), HealthCheckTimeout)
+				sub, err := next.getClient().SubscribeFilterLogs(ctx, query, ch)
+				cancel()
+				next.observe(0, isEndpointError(ctx, err))
+				if err != nil {
+					log.Warn().Err(err).Str("endpoint", next.Name()).Msg("could not move subscription to another endpoint")
+					continue
+				}
+				log.Debug().Str("from", e.Name()).Str("to", next.Name()).Msg("subscription moved to another endpoint")
+				inner.Unsubscribe()
+				inner, e = sub, next
+			case err := <-inner.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// subscribe creates subscription on the first endpoint that accepts it, starting from the best one
+func (p *Pool) subscribe(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, *Endpoint, error) {
+	lastErr := ErrNoSubscriptions
+	for _, e := range p.ranked() {
+		if !e.CanSubscribe() {
+			continue
+		}
+		sub, err := e.getClient().SubscribeFilterLogs(ctx, query, ch)
+		e.observe(0, isEndpointError(ctx, err))
+		if err == nil {
+			log.Debug().Str("endpoint", e.Name()).Msg("subscribed for logs")
+			return sub, e, nil
+		}
+		lastErr = err
+	}
+	return nil, nil, lastErr
+}
+
+// getSubscriber returns the best endpoint supporting subscriptions, as of the last health check
+func (p *Pool) getSubscriber() *Endpoint {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.subscriber
+}
+
+// updateSubscriber picks the best endpoint supporting subscriptions from ranked ones, and tells subscriptions
+// to move to it when it changes
+func (p *Pool) updateSubscriber(ranked []*Endpoint) {
+	var best *Endpoint
+	for _, e := range ranked {
+		if e.CanSubscribe() {
+			best = e
+			break
+		}
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if best == nil || best == p.subscriber {
+		return
+	}
+	if p.subscriber != nil {
+		log.Info().Str("from", p.subscriber.Name()).Str("to", best.Name()).Msg("switching subscriptions to another endpoint")
+	}
+	p.subscriber = best
+	for moved := range p.subscriptions {
+		select {
+		case moved <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package selector
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidCSVQuery = errors.New("invalid csv query provided")
+	ErrNoSuchColumn    = errors.New("no such column was found")
+	ErrInvalidRow      = errors.New("csv query has to match exactly one row")
+
+	delimiterNames = map[string]rune{
+		"comma":     ',',
+		"semicolon": ';',
+		"tab":       '\t',
+		"pipe":      '|',
+	}
+)
+
+func init() {
+	Register("csv", Engine{Accept: "text/csv", Select: delimitedSelector(',')})
+	Register("tsv", Engine{Accept: "text/tab-separated-values", Select: delimitedSelector('\t')})
+}
+
+// csvQuery describes which cell is selected from a CSV document.
+// Queries are encoded like URL query strings, e.g. "column=price&where=symbol=BTC" or "column=2&row=-1&header=false".
+// Delimiter is either a single URL-encoded character, or one of "comma", "semicolon", "tab" and "pipe".
+type csvQuery struct {
+	// column contains column name, or zero-based column index
+	column string
+	// row contains zero-based index of a data row; negative indexes are counted from the end
+	row *int
+	// whereColumn and whereValue select a row which cell in whereColumn equals whereValue
+	whereColumn string
+	whereValue  string
+	// header is either "true", "false" or "auto"
+	header    string
+	delimiter rune
+}
+
+func parseCSVQuery(query string, delimiter rune) (*csvQuery, error) {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	q := &csvQuery{
+		column:    values.Get("column"),
+		header:    values.Get("header"),
+		delimiter: delimiter,
+	}
+	if q.column == "" {
+		return nil, ErrInvalidCSVQuery
+	}
+	if q.header == "" {
+		q.header = "auto"
+	}
+	if q.header != "auto" && q.header != "true" && q.header != "false" {
+		return nil, ErrInvalidCSVQuery
+	}
+	if d := values.Get("delimiter"); d != "" {
+		if named, ok := delimiterNames[d]; ok {
+			q.delimiter = named
+		} else if utf8.RuneCountInString(d) == 1 {
+			q.delimiter, _ = utf8.DecodeRuneInString(d)
+		} else {
+			return nil, ErrInvalidCSVQuery
+		}
+	}
+	if r := values.Get("row"); r != "" {
+		row, err := strconv.Atoi(r)
+		if err != nil {
+			return nil, ErrInvalidCSVQuery
+		}
+		q.row = &row
+	}
+	if w := values.Get("where"); w != "" {
+		split := strings.SplitN(w, "=", 2)
+		if len(split) != 2 || q.row != nil {
+			return nil, ErrInvalidCSVQuery
+		}
+		q.whereColumn, q.whereValue = split[0], split[1]
+	}
+	return q, nil
+}
+
+// delimitedSelector returns selector for CSV documents with the default delimiter.
+// Selected value is normalized with NormalizeNumber if it is a number.
+func delimitedSelector(delimiter rune) Selector {
+	return func(data []byte, query string) (string, error) {
+		q, err := parseCSVQuery(query, delimiter)
+		if err != nil {
+			return "", err
+		}
+		r := csv.NewReader(strings.NewReader(string(data)))
+		r.Comma = q.delimiter
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		r.TrimLeadingSpace = true
+		var records [][]string
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			for i := range record {
+				record[i] = strings.TrimSpace(record[i])
+			}
+			records = append(records, record)
+		}
+		if len(records) == 0 {
+			return "", ErrInvalidRow
+		}
+
+		var header []string
+		if q.header == "true" || (q.header == "auto" && detectHeader(records[0])) {
+			header, records = records[0], records[1:]
+		}
+		column, err := findColumn(header, q.column)
+		if err != nil {
+			return "", err
+		}
+
+		var row []string
+		switch {
+		case q.row != nil:
+			index := *q.row
+			if index < 0 {
+				index += len(records)
+			}
+			if index < 0 || index >= len(records) {
+				return "", ErrInvalidRow
+			}
+			row = records[index]
+		case q.whereColumn != "":
+			whereColumn, err := findColumn(header, q.whereColumn)
+			if err != nil {
+				return "", err
+			}
+			for _, record := range records {
+				if whereColumn < len(record) && record[whereColumn] == q.whereValue {
+					if row != nil {
+						return "", ErrInvalidRow
+					}
+					row = record
+				}
+			}
+		case len(records) == 1:
+			row = records[0]
+		}
+		if row == nil {
+			return "", ErrInvalidRow
+		}
+		if column >= len(row) {
+			return "", ErrNoSuchColumn
+		}
+		if number, ok := NormalizeNumber(row[column]); ok {
+			return number, nil
+		}
+		return row[column], nil
+	}
+}
+
+// detectHeader assumes that the first row is a header if it doesn't contain numbers
+func detectHeader(record []string) bool {
+	for _, v := range record {
+		if _, ok := NormalizeNumber(v); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// findColumn looks up column by name in the header, or by its zero-based index
+func findColumn(header []string, column string) (int, error) {
+	for i, name := range header {
+		if name == column {
+			return i, nil
+		}
+	}
+	index, err := strconv.Atoi(column)
+	if err != nil || index < 0 {
+		return 0, ErrNoSuchColumn
+	}
+	return index, nil
+}
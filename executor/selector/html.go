@@ -0,0 +1,74 @@
+package selector
+
+import (
+	"bytes"
+	"errors"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlExtraction matches "@attribute" suffix of HTML queries
+	htmlExtraction = regexp.MustCompile(`@([a-zA-Z_:][-a-zA-Z0-9_:.]*)$`)
+
+	ErrInvalidCSSSelector = errors.New("invalid css selector provided")
+	ErrNoSuchAttribute    = errors.New("no such attribute was found")
+)
+
+func init() {
+	Register("html", Engine{Accept: "text/html", Select: htmlSelector})
+}
+
+// htmlSelector selects exactly one element using CSS selector.
+// By default, text of the element is returned with whitespace collapsed.
+// Selector can be followed by "@rawtext" to return text as is, or by "@name" to return value of an attribute,
+// e.g. "div.price > span" or "a.download@href".
+func htmlSelector(data []byte, query string) (string, error) {
+	extraction := "text"
+	if match := htmlExtraction.FindStringSubmatchIndex(query); match != nil {
+		extraction = query[match[2]:match[3]]
+		query = query[:match[0]]
+	}
+	sel, err := cascadia.Compile(query)
+	if err != nil {
+		return "", err
+	}
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	nodes := sel.MatchAll(doc)
+	if len(nodes) != 1 {
+		return "", ErrInvalidCSSSelector
+	}
+	node := nodes[0]
+	switch extraction {
+	case "text":
+		return strings.Join(strings.Fields(nodeText(node)), " "), nil
+	case "rawtext":
+		return nodeText(node), nil
+	}
+	for _, attr := range node.Attr {
+		if attr.Namespace == "" && attr.Key == extraction {
+			return attr.Val, nil
+		}
+	}
+	return "", ErrNoSuchAttribute
+}
+
+func nodeText(node *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(node)
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package selector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/itchyny/gojq"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// JqTimeout limits time that a jq program can run, so endless programs like "repeat(.)" can't hang the node
+var JqTimeout = 1 * time.Second
+
+var (
+	ErrInvalidJq = errors.New("jq program has to produce exactly one value")
+	ErrJqNull    = errors.New("jq program produced null")
+	ErrJqNaN     = errors.New("jq program produced a number that is not finite")
+)
+
+func init() {
+	Register("jq", Engine{Accept: "application/json", Select: jqSelector})
+}
+
+// jqSelector runs a jq program, which has to produce exactly one value.
+// Numbers are formatted without exponent, and arrays and objects are encoded as JSON with sorted keys,
+// so that every node produces the same string.
+func jqSelector(data []byte, query string) (string, error) {
+	q, err := gojq.Parse(query)
+	if err != nil {
+		return "", err
+	}
+	// Environment variables are not accessible, since environment loader is not provided
+	code, err := gojq.Compile(q)
+	if err != nil {
+		return "", err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	err = dec.Decode(&v)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), JqTimeout)
+	defer cancel()
+	iter := code.RunWithContext(ctx, v)
+	result, ok := iter.Next()
+	if !ok {
+		return "", ErrInvalidJq
+	}
+	if err, ok := result.(error); ok {
+		return "", err
+	}
+	if _, ok := iter.Next(); ok {
+		return "", ErrInvalidJq
+	}
+	return formatJqValue(result)
+}
+
+func formatJqValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", ErrJqNull
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case *big.Int:
+		return v.String(), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", ErrJqNaN
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		// Arrays and objects; encoding/json sorts object keys
+		out, err := json.Marshal(v)
+		return string(out), err
+	}
+}
@@ -0,0 +1,98 @@
+package selector
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/oliveagle/jsonpath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// JSONPathHotfix converts bracketed keys into dotted ones, because jsonpath library can't handle them
+	JSONPathHotfix = regexp.MustCompile("(?U)\\[\"(.+)\"]")
+
+	ErrInvalidJSONPath = errors.New("invalid jsonpath provided")
+	ErrNoSuchKey       = errors.New("no such key was found")
+	ErrInvalidIndex    = errors.New("invalid index")
+	ErrIndexOutOfRange = errors.New("index out of range")
+	ErrUnexpectedValue = errors.New("unexpected value")
+	ErrInvalidEndValue = errors.New("invalid end value")
+)
+
+func jsonPathSelector(data []byte, query string) (string, error) {
+	query = JSONPathHotfix.ReplaceAllString(query, ".$1")
+	q, err := jsonpath.Compile(query)
+	if err != nil {
+		return "", err
+	}
+	var v interface{}
+	err = json.Unmarshal(data, &v)
+	if err != nil {
+		return "", err
+	}
+	resp, err := q.Lookup(v)
+	if err != nil {
+		return "", err
+	}
+	switch r := resp.(type) {
+	case string:
+		return r, nil
+	case float64:
+		return strconv.FormatFloat(r, 'f', -1, 64), nil
+	default:
+		return "", ErrInvalidJSONPath
+	}
+}
+
+// jsonSelector walks JSON using a path of bracketed keys and indexes, e.g. "[bitcoin][usd]" or "[data][0][price]".
+// It only selects numbers.
+func jsonSelector(data []byte, query string) (string, error) {
+	v := map[string]interface{}{}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return "", err
+	}
+	split := strings.Split(query, "]")
+	for i, v := range split {
+		split[i] = strings.Replace(v, "[", "", 1)
+	}
+	var current interface{}
+	current = v
+	for i, element := range split {
+		if i == len(split)-1 {
+			// last element in split is guaranteed to be empty
+			break
+		}
+		switch v := current.(type) {
+		case map[string]interface{}:
+			value, ok := v[element]
+			if !ok {
+				return "", ErrNoSuchKey
+			}
+			current = value
+		case []interface{}:
+			value, err := strconv.ParseInt(element, 10, 64)
+			if err != nil {
+				return "", ErrInvalidIndex
+			}
+			if int(value) < 0 {
+				return "", ErrIndexOutOfRange
+			}
+			if int(value) >= len(v) {
+				return "", ErrIndexOutOfRange
+			}
+			current = v[value]
+		default:
+			return "", ErrUnexpectedValue
+		}
+	}
+	switch v := current.(type) {
+	case float64:
+		// encoding/json always decodes numbers as float64
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", ErrInvalidEndValue
+	}
+}
@@ -0,0 +1,18 @@
+package selector
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NormalizeNumber checks whether value is a number, accepting a comma as the decimal separator.
+// It returns the number with a period as the decimal separator.
+func NormalizeNumber(value string) (string, bool) {
+	_, err := strconv.ParseFloat(value, 64)
+	if err == nil {
+		return value, true
+	}
+	value = strings.Replace(value, ",", ".", 1)
+	_, err = strconv.ParseFloat(value, 64)
+	return value, err == nil
+}
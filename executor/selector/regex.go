@@ -0,0 +1,92 @@
+package selector
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+)
+
+var (
+	// MaxRegexpLength limits length of regular expressions
+	MaxRegexpLength = 512
+	// MaxRegexpSize limits size of regular expressions after repetitions are expanded, e.g. "(a{100}){100}" has size 10000
+	MaxRegexpSize = 4096
+	// MaxRegexpInput limits size of data that regular expressions are matched against
+	MaxRegexpInput = 1 << 20
+	// MaxRegexpWork limits size of regular expression multiplied by size of data, which bounds matching time,
+	// as it is linear in both. It takes about half a second to match the largest allowed combination.
+	MaxRegexpWork = 1 << 25
+
+	ErrRegexpTooLarge = errors.New("regular expression is too large")
+	ErrRegexpInput    = errors.New("response is too large for a regular expression")
+	ErrRegexpNoMatch  = errors.New("regular expression did not match")
+)
+
+func init() {
+	Register("regex", Engine{Accept: "text/plain", Select: regexSelector})
+}
+
+// regexSelector returns a capture group of the first match of a regular expression.
+// Group named "value" is returned if it exists, otherwise the first group, or the whole match if there are no groups.
+// Go regular expressions are guaranteed to run in time linear in size of both expression and data,
+// so limiting their product makes sure that this time is short.
+func regexSelector(data []byte, query string) (string, error) {
+	re, size, err := compileSafeRegexp(query)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxRegexpInput || size*len(data) > MaxRegexpWork {
+		return "", ErrRegexpInput
+	}
+
+	match := re.FindSubmatchIndex(data)
+	if match == nil {
+		return "", ErrRegexpNoMatch
+	}
+
+	group := 0
+	if index := re.SubexpIndex("value"); index > 0 {
+		group = index
+	} else if re.NumSubexp() > 0 {
+		group = 1
+	}
+	start, end := match[2*group], match[2*group+1]
+	if start < 0 {
+		return "", ErrRegexpNoMatch
+	}
+	return string(data[start:end]), nil
+}
+
+// compileSafeRegexp compiles regular expression if it is small enough, and returns it with its estimated size
+func compileSafeRegexp(query string) (*regexp.Regexp, int, error) {
+	if len(query) > MaxRegexpLength {
+		return nil, 0, ErrRegexpTooLarge
+	}
+	parsed, err := syntax.Parse(query, syntax.Perl)
+	if err != nil {
+		return nil, 0, err
+	}
+	size := regexpSize(parsed)
+	if size > MaxRegexpSize {
+		return nil, 0, ErrRegexpTooLarge
+	}
+	re, err := regexp.Compile(query)
+	return re, size, err
+}
+
+// regexpSize estimates amount of instructions that regular expression compiles into
+func regexpSize(re *syntax.Regexp) int {
+	size := 1
+	for _, sub := range re.Sub {
+		size += regexpSize(sub)
+	}
+	if re.Op == syntax.OpRepeat {
+		count := re.Max
+		if count < re.Min {
+			// Unbounded repetition
+			count = re.Min + 1
+		}
+		size *= count
+	}
+	return size
+}
@@ -0,0 +1,92 @@
+package selector
+
+import (
+	"errors"
+	"strings"
+)
+
+// Selector extracts a single value from the data source response
+type Selector func(data []byte, query string) (string, error)
+
+// Engine describes a query language that can be used to select values
+type Engine struct {
+	// Accept contains value of Accept header that is sent to data sources queried with this engine
+	Accept string
+	// Select executes the query
+	Select Selector
+}
+
+var (
+	engines = map[string]Engine{
+		"json":     {Accept: "application/json", Select: jsonSelector},
+		"jsonpath": {Accept: "application/json", Select: jsonPathSelector},
+		"xpath":    {Accept: "application/xml", Select: xpathSelector},
+	}
+	// implicitEngines maps first character of queries without engine prefix to the engine
+	implicitEngines = map[byte]string{
+		'$': "jsonpath",
+		'/': "xpath",
+	}
+
+	ErrUnknownQuery = errors.New("unknown query provided")
+)
+
+// Register adds a new engine, which will be used for queries prefixed with "name:".
+// It is not safe to call Register concurrently with other functions, so it should be called from init.
+func Register(name string, engine Engine) {
+	engines[name] = engine
+}
+
+// Has reports whether an engine with this name exists
+func Has(name string) bool {
+	_, ok := engines[name]
+	return ok
+}
+
+// Resolve finds an engine for the query and returns the query without engine prefix.
+// Queries are either prefixed with engine name and a colon (e.g. "xpath://price"),
+// or start with a character that is known to belong to an engine ("$" for JSONPath and "/" for XPath).
+func Resolve(query string) (Engine, string, error) {
+	if query == "" {
+		return Engine{}, "", ErrUnknownQuery
+	}
+	if name, ok := implicitEngines[query[0]]; ok {
+		return engines[name], query, nil
+	}
+	split := strings.SplitN(query, ":", 2)
+	if len(split) != 2 {
+		return Engine{}, "", ErrUnknownQuery
+	}
+	engine, ok := engines[split[0]]
+	if !ok {
+		return Engine{}, "", ErrUnknownQuery
+	}
+	return engine, split[1], nil
+}
+
+// Accept returns value of Accept header for the query, or an empty string if query is unknown
+func Accept(query string) string {
+	engine, _, err := Resolve(query)
+	if err != nil {
+		return ""
+	}
+	return engine.Accept
+}
+
+// Execute runs query against the data, choosing engine with Resolve
+func Execute(data []byte, query string) (string, error) {
+	engine, query, err := Resolve(query)
+	if err != nil {
+		return "", err
+	}
+	return engine.Select(data, query)
+}
+
+// Select runs query against the data using engine with the provided name
+func Select(name string, data []byte, query string) (string, error) {
+	engine, ok := engines[name]
+	if !ok {
+		return "", ErrUnknownQuery
+	}
+	return engine.Select(data, query)
+}
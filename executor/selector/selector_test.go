@@ -0,0 +1,204 @@
+package selector
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	jsonData := []byte(`{"bitcoin": {"usd": 56000.5}, "name": "Bitcoin", "list": [{"price": 1}, {"price": 2}]}`)
+	xmlData := []byte(`<root><price>123.4</price><item>1</item><item>2</item></root>`)
+	tests := []struct {
+		name    string
+		data    []byte
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"test implicit jsonpath", jsonData, "$.bitcoin.usd", "56000.5", false},
+		{"test bracketed jsonpath", jsonData, `$["bitcoin"]["usd"]`, "56000.5", false},
+		{"test prefixed jsonpath", jsonData, "jsonpath:$.name", "Bitcoin", false},
+		{"test jsonpath object", jsonData, "$.bitcoin", "", true},
+		{"test bracket path", jsonData, "json:[list][1][price]", "2", false},
+		{"test bracket path out of range", jsonData, "json:[list][2][price]", "", true},
+		{"test implicit xpath", xmlData, "/root/price/text()", "123.4", false},
+		{"test prefixed xpath", xmlData, "xpath://price/text()", "123.4", false},
+		{"test xpath with multiple results", xmlData, "//item", "", true},
+		{"test empty query", jsonData, "", "", true},
+		{"test unknown engine", jsonData, "unknown:query", "", true},
+		{"test unprefixed query", jsonData, "price", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Execute(tt.data, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccept(t *testing.T) {
+	if got := Accept("$.price"); got != "application/json" {
+		t.Errorf("Accept() = %v, want application/json", got)
+	}
+	if got := Accept("xpath://price"); got != "application/xml" {
+		t.Errorf("Accept() = %v, want application/xml", got)
+	}
+	if got := Accept("unknown"); got != "" {
+		t.Errorf("Accept() = %v, want empty string", got)
+	}
+}
+
+func TestJq(t *testing.T) {
+	data := []byte(`{"tickers": [{"symbol": "BTC", "price": 56000.5, "volume": 10}, {"symbol": "ETH", "price": 3000, "volume": 2.5}], "big": 123456789012345678901234567890}`)
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"test filter", `jq:.tickers[] | select(.symbol == "BTC") | .price`, "56000.5", false},
+		{"test sum", `jq:[.tickers[].volume] | add`, "12.5", false},
+		{"test integer", `jq:.tickers[1].price`, "3000", false},
+		{"test big integer", `jq:.big`, "123456789012345678901234567890", false},
+		{"test small float without exponent", `jq:.tickers[0].volume / 1e9`, "0.00000001", false},
+		{"test object with sorted keys", `jq:{b: 1, a: 2}`, `{"a":2,"b":1}`, false},
+		{"test multiple values", `jq:.tickers[].price`, "", true},
+		{"test no values", `jq:empty`, "", true},
+		{"test null", `jq:.missing`, "", true},
+		{"test infinite loop", `jq:last(repeat(.))`, "", true},
+		{"test environment is inaccessible", `jq:$ENV.PATH`, "", true},
+		{"test syntax error", `jq:.[`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Execute(data, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	data := []byte(`<html><body>
+<div class="price">
+  <span id="btc" data-value="56000.5">
+    56 000.5
+    <b>USD</b>
+  </span>
+  <span id="eth">3000<p>unclosed
+</div>
+<a href="https://example.com/file.csv" class="download">Download</a>
+</body>`)
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"test normalized text", "html:#btc", "56 000.5 USD", false},
+		{"test raw text", "html:span#eth@rawtext", "3000unclosed\n", false},
+		{"test attribute", "html:#btc@data-value", "56000.5", false},
+		{"test attribute with selector", `html:a[href$=".csv"]@href`, "https://example.com/file.csv", false},
+		{"test missing attribute", "html:#btc@title", "", true},
+		{"test multiple elements", "html:div.price span", "", true},
+		{"test no elements", "html:table", "", true},
+		{"test invalid selector", "html:div[", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Execute(data, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSV(t *testing.T) {
+	data := []byte("symbol,price,volume\nBTC,56000.5,10\nETH,\"3000,25\",2\n")
+	semicolons := []byte("BTC;56000,5\nETH;3000\n")
+	tsv := []byte("name\tvalue\nrate\t1.25\n")
+	tests := []struct {
+		name    string
+		data    []byte
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"test column name and where", data, "csv:column=price&where=symbol=BTC", "56000.5", false},
+		{"test comma in number", data, "csv:column=price&where=symbol=ETH", "3000.25", false},
+		{"test column index and row", data, "csv:column=2&row=0", "10", false},
+		{"test negative row", data, "csv:column=symbol&row=-1", "ETH", false},
+		{"test header as a row", data, "csv:column=0&row=0&header=false", "symbol", false},
+		{"test no header detected", semicolons, "csv:column=1&where=0=BTC&delimiter=%3B", "56000.5", false},
+		{"test named delimiter", semicolons, "csv:column=1&row=1&delimiter=semicolon", "3000", false},
+		{"test tsv with single row", tsv, "tsv:column=value", "1.25", false},
+		{"test multiple rows", data, "csv:column=price", "", true},
+		{"test unknown column", data, "csv:column=bid&row=0", "", true},
+		{"test where without match", data, "csv:column=price&where=symbol=DOGE", "", true},
+		{"test row out of range", data, "csv:column=price&row=5", "", true},
+		{"test missing column", data, "csv:row=1", "", true},
+		{"test row and where", data, "csv:column=price&row=1&where=symbol=BTC", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Execute(tt.data, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegex(t *testing.T) {
+	data := []byte("BTC: 56000.5 USD\nETH: 3000 USD\nstatus: operational\n")
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"test named group", `regex:(?m)^(?P<symbol>ETH): (?P<value>[\d.]+)`, "3000", false},
+		{"test first group", `regex:BTC: ([\d.]+)`, "56000.5", false},
+		{"test whole match", `regex:operational|degraded`, "operational", false},
+		{"test no match", `regex:DOGE: ([\d.]+)`, "", true},
+		{"test unmatched optional group", `regex:status: (x)?`, "", true},
+		{"test invalid expression", `regex:(`, "", true},
+		{"test nested repetition", `regex:((a{100}){100}){100}`, "", true},
+		{"test long expression", "regex:" + string(make([]byte, 1024)), "", true},
+	}
+	// Both expression and data are within limits, but matching them would take too long
+	large := bytes.Repeat([]byte("a"), 1<<20)
+	if _, err := Execute(large, "regex:[a-z]{1,1000}[a-z]{1,1000}x"); err != ErrRegexpInput {
+		t.Errorf("Execute() error = %v, want %v", err, ErrRegexpInput)
+	}
+	if got, err := Execute(large, "regex:a{3}"); err != nil || got != "aaa" {
+		t.Errorf("Execute() = %q, %v, want aaa", got, err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Execute(data, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package selector
+
+import (
+	"bytes"
+	"errors"
+	"github.com/antchfx/xmlquery"
+)
+
+var (
+	ErrInvalidXPath = errors.New("invalid xpath provided")
+)
+
+func xpathSelector(data []byte, query string) (string, error) {
+	doc, err := xmlquery.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	nodes, err := xmlquery.QueryAll(doc, query)
+	if err != nil {
+		return "", err
+	}
+	if len(nodes) != 1 {
+		return "", ErrInvalidXPath
+	}
+	return nodes[0].Data, nil
+}
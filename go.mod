@@ -3,16 +3,17 @@ module github.com/orakurudata/crystal-ball
 go 1.16
 
 require (
+	github.com/andybalholm/cascadia v1.2.0
 	github.com/antchfx/xmlquery v1.3.6
 	github.com/btcsuite/btcd v0.21.0-beta // indirect
 	github.com/ethereum/go-ethereum v1.10.2
-	github.com/huin/goupnp v1.0.1-0.20210310174557-0ca763054c88 // indirect
-	github.com/mattn/go-sqlite3 v1.14.7 // indirect
+	github.com/itchyny/gojq v0.12.4
+	github.com/mattn/go-sqlite3 v1.14.7
 	github.com/oliveagle/jsonpath v0.0.0-20180606110733-2e52cf6e6852
-	github.com/prometheus/client_golang v1.10.0 // indirect
+	github.com/prometheus/client_golang v1.10.0
 	github.com/rs/zerolog v1.21.0
-	github.com/shopspring/decimal v1.2.0 // indirect
-	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b // indirect
-	golang.org/x/sys v0.0.0-20210423185535-09eb48e85fd7 // indirect
+	github.com/shopspring/decimal v1.2.0
+	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b
+	golang.org/x/net v0.0.0-20210226172049-e18ecbb05110
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
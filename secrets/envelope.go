@@ -0,0 +1,267 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvelopeVersion1 is the first version of the multi-recipient envelope
+	EnvelopeVersion1 = 1
+	// EnvelopeVersion2 adds the host the secret is bound to
+	EnvelopeVersion2 = 2
+	// EnvelopePrefix is used instead of the sender public key in encoded envelopes, e.g. $$env:<base64>$$
+	EnvelopePrefix = "env"
+	// KeyIDSize is the size of recipient key identifiers
+	KeyIDSize = 8
+	// MaxRecipients limits amount of recipients of a single envelope
+	MaxRecipients = 255
+	// MaxHostLength limits length of the host an envelope is bound to
+	MaxHostLength = 255
+
+	keySize         = chacha20poly1305.KeySize
+	nonceSize       = chacha20poly1305.NonceSize
+	overhead        = 16
+	wrappedKeySize  = nonceSize + keySize + overhead
+	recipientSize   = KeyIDSize + wrappedKeySize
+	envelopeHeader  = 1 + keySize + 1
+	minEnvelopeSize = envelopeHeader + recipientSize + nonceSize + overhead
+)
+
+var (
+	ErrInvalidEnvelope     = errors.New("invalid envelope")
+	ErrUnsupportedEnvelope = errors.New("unsupported envelope version")
+	ErrNotRecipient        = errors.New("envelope is not encrypted for this key")
+	ErrNoRecipients        = errors.New("envelope has to have between 1 and 255 recipients")
+	ErrInvalidHost         = errors.New("envelope host has to be between 1 and 255 characters long")
+)
+
+// Envelope is a secret that can be decrypted by any of several recipients.
+// Message is encrypted with a random content key, which is wrapped for every recipient with a key
+// derived from the ephemeral sender key and the recipient public key.
+//
+// Binary layout is: version (1 byte), host length (1 byte) and host for version 2 only, sender public key (32 bytes),
+// amount of recipients (1 byte), recipients (key ID and wrapped content key each), nonce and ciphertext of the message.
+// Everything before the message nonce is authenticated as associated data of the message,
+// so neither the recipients nor the host can be changed without breaking decryption.
+type Envelope struct {
+	Version byte
+	// Host contains the host the secret may only be sent to, it's empty for version 1
+	Host       string
+	Sender     PublicKey
+	Recipients []Recipient
+	Ciphertext []byte
+
+	// header contains raw bytes of everything before Ciphertext
+	header []byte
+}
+
+// Recipient contains content key of the envelope wrapped for a single recipient
+type Recipient struct {
+	KeyID      []byte
+	WrappedKey []byte
+}
+
+// KeyID returns a short identifier of a public key, which lets recipients find their entry in an envelope
+func KeyID(point PublicKey) []byte {
+	sum := sha256.Sum256(point)
+	return sum[:KeyIDSize]
+}
+
+// EncryptEnvelope encrypts message for all recipients and returns the encoded envelope
+func EncryptEnvelope(recipients []PublicKey, message string) (string, error) {
+	return encryptEnvelope(recipients, "", message)
+}
+
+// EncryptBoundEnvelope encrypts message for all recipients, binding it to host. Host is either a hostname
+// or a wildcard like *.example.com, which matches all subdomains. See MatchHost.
+func EncryptBoundEnvelope(recipients []PublicKey, host, message string) (string, error) {
+	if host == "" || len(host) > MaxHostLength {
+		return "", ErrInvalidHost
+	}
+	return encryptEnvelope(recipients, strings.ToLower(host), message)
+}
+
+func encryptEnvelope(recipients []PublicKey, host, message string) (string, error) {
+	if len(recipients) == 0 || len(recipients) > MaxRecipients {
+		return "", ErrNoRecipients
+	}
+	sender, err := GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	senderPub, err := PublicKeyFromSeed(sender)
+	if err != nil {
+		return "", err
+	}
+	contentKey := make([]byte, keySize)
+	_, err = rand.Read(contentKey)
+	if err != nil {
+		return "", err
+	}
+
+	header := []byte{EnvelopeVersion1}
+	if host != "" {
+		header = append([]byte{EnvelopeVersion2, byte(len(host))}, host...)
+	}
+	header = append(header, senderPub...)
+	header = append(header, byte(len(recipients)))
+	for _, point := range recipients {
+		id := KeyID(point)
+		wrapped, err := seal(sender, point, contentKey, id)
+		if err != nil {
+			return "", err
+		}
+		header = append(header, id...)
+		header = append(header, wrapped...)
+	}
+	cipher, err := chacha20poly1305.New(contentKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, nonceSize)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	out := append(header, nonce...)
+	out = cipher.Seal(out, nonce, []byte(message), header)
+	return EncodeEnvelope(out), nil
+}
+
+// EncodeEnvelope encodes binary envelope the same way Encode does with regular secrets
+func EncodeEnvelope(data []byte) string {
+	return "$$" + EnvelopePrefix + ":" + base64.StdEncoding.EncodeToString(data) + "$$"
+}
+
+// ParseEnvelope decodes binary envelope without decrypting it
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	if len(data) < minEnvelopeSize {
+		return nil, ErrInvalidEnvelope
+	}
+	e := &Envelope{
+		Version: data[0],
+	}
+	offset := 1
+	switch e.Version {
+	case EnvelopeVersion1:
+	case EnvelopeVersion2:
+		length := int(data[1])
+		offset = 2 + length
+		if length == 0 || len(data) < offset-1+minEnvelopeSize {
+			return nil, ErrInvalidEnvelope
+		}
+		e.Host = string(data[2:offset])
+	default:
+		return nil, ErrUnsupportedEnvelope
+	}
+	e.Sender = data[offset : offset+keySize]
+	count := int(data[offset+keySize])
+	offset += keySize + 1
+	if count == 0 || len(data) < offset+count*recipientSize+nonceSize+overhead {
+		return nil, ErrInvalidEnvelope
+	}
+	for i := 0; i < count; i++ {
+		e.Recipients = append(e.Recipients, Recipient{
+			KeyID:      data[offset : offset+KeyIDSize],
+			WrappedKey: data[offset+KeyIDSize : offset+recipientSize],
+		})
+		offset += recipientSize
+	}
+	e.header = data[:offset]
+	e.Ciphertext = data[offset:]
+	return e, nil
+}
+
+// Open decrypts the envelope with seed of one of its recipients
+func (e *Envelope) Open(seed Seed) (string, error) {
+	point, err := PublicKeyFromSeed(seed)
+	if err != nil {
+		return "", err
+	}
+	id := KeyID(point)
+	for _, r := range e.Recipients {
+		if !bytes.Equal(r.KeyID, id) {
+			continue
+		}
+		contentKey, err := open(seed, e.Sender, r.WrappedKey, id)
+		if err != nil {
+			// Key IDs are short, so they can collide
+			continue
+		}
+		cipher, err := chacha20poly1305.New(contentKey)
+		if err != nil {
+			return "", err
+		}
+		nonce := e.Ciphertext[:nonceSize]
+		out, err := cipher.Open(nil, nonce, e.Ciphertext[nonceSize:], e.header)
+		return string(out), err
+	}
+	return "", ErrNotRecipient
+}
+
+// OpenEnvelope parses binary envelope and decrypts it with seed
+func OpenEnvelope(seed Seed, data []byte) (string, error) {
+	e, err := ParseEnvelope(data)
+	if err != nil {
+		return "", err
+	}
+	return e.Open(seed)
+}
+
+// seal encrypts data with a key shared between seed and point
+func seal(seed Seed, point PublicKey, data, ad []byte) ([]byte, error) {
+	key, err := SharedSecret(seed, point)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Seal(nonce, nonce, data, ad), nil
+}
+
+// open decrypts data sealed with seal
+func open(seed Seed, point PublicKey, data, ad []byte) ([]byte, error) {
+	key, err := SharedSecret(seed, point)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Open(nil, data[:nonceSize], data[nonceSize:], ad)
+}
+
+// MatchHost checks whether host satisfies the binding of an envelope. Binding is either a hostname, which has to be
+// equal to host, or a wildcard like *.example.com, which matches any subdomain of example.com but not example.com itself
+func MatchHost(binding, host string) bool {
+	binding, host = strings.ToLower(binding), strings.ToLower(host)
+	if strings.HasPrefix(binding, "*.") {
+		return len(host) > len(binding)-1 && strings.HasSuffix(host, binding[1:])
+	}
+	return binding == host
+}
+
+// Unwrap decrypts a single encoded secret, which is either an envelope or a secret encoded with Encode.
+// Envelopes bound to a host are only decrypted if host matches the binding.
+func Unwrap(seed Seed, blob, host string) (string, error) {
+	key, err := NewKey(seed, time.Time{})
+	if err != nil {
+		return "", err
+	}
+	return Keyring{key}.Unwrap(blob, host, time.Now())
+}
@@ -0,0 +1,115 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNoKeys       = errors.New("keyring contains no active keys")
+	ErrHostMismatch = errors.New("secret is bound to another host")
+)
+
+// Key is a seed of the keyring. Retired keys are not used after RetireAt, zero RetireAt means the key never retires
+type Key struct {
+	ID       []byte
+	Seed     Seed
+	Public   PublicKey
+	RetireAt time.Time
+}
+
+// Keyring contains all seeds of the node, so that secrets encrypted for previous keys can be decrypted during rotation
+type Keyring []Key
+
+// NewKey derives public key and key ID of seed
+func NewKey(seed Seed, retireAt time.Time) (Key, error) {
+	public, err := PublicKeyFromSeed(seed)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{
+		ID:       KeyID(public),
+		Seed:     seed,
+		Public:   public,
+		RetireAt: retireAt,
+	}, nil
+}
+
+// Retired returns whether key is retired at t
+func (k Key) Retired(t time.Time) bool {
+	return !k.RetireAt.IsZero() && !t.Before(k.RetireAt)
+}
+
+// Active returns keys that are not retired at t
+func (k Keyring) Active(t time.Time) Keyring {
+	var out Keyring
+	for _, key := range k {
+		if !key.Retired(t) {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// Unwrap decrypts a single encoded secret with keys active at t, refusing to decrypt secrets bound to a host
+// other than host
+func (k Keyring) Unwrap(blob, host string, t time.Time) (string, error) {
+	value, binding, err := k.Open(blob, t)
+	if err != nil {
+		return "", err
+	}
+	if binding != "" && !MatchHost(binding, host) {
+		return "", ErrHostMismatch
+	}
+	return value, nil
+}
+
+// Open decrypts a single encoded secret with keys active at t and returns it with the host it's bound to.
+// Zero t selects all keys, including retired ones.
+// Envelopes are opened with the key matching one of their key IDs, while regular secrets don't identify
+// the recipient and every active key is tried. Regular secrets and version 1 envelopes are not bound to any host.
+func (k Keyring) Open(blob string, t time.Time) (value, binding string, err error) {
+	keys := k.Active(t)
+	if len(keys) == 0 {
+		return "", "", ErrNoKeys
+	}
+	match := BlobRegexp.FindStringSubmatch(blob)
+	if match == nil || match[0] != blob {
+		return "", "", ErrInvalidEncoding
+	}
+	if match[BlobRegexp.SubexpIndex("key")] != EnvelopePrefix {
+		point, data, err := Decode(blob)
+		if err != nil {
+			return "", "", err
+		}
+		for _, key := range keys {
+			value, err := Decrypt(key.Seed, point, data)
+			if err == nil {
+				return value, "", nil
+			}
+		}
+		return "", "", ErrNotRecipient
+	}
+	data, err := base64.StdEncoding.DecodeString(match[BlobRegexp.SubexpIndex("data")])
+	if err != nil {
+		return "", "", err
+	}
+	e, err := ParseEnvelope(data)
+	if err != nil {
+		return "", "", err
+	}
+	for _, r := range e.Recipients {
+		for _, key := range keys {
+			if !bytes.Equal(r.KeyID, key.ID) {
+				continue
+			}
+			value, err := e.Open(key.Seed)
+			if err == nil {
+				return value, e.Host, nil
+			}
+		}
+	}
+	return "", "", ErrNotRecipient
+}
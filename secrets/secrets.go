@@ -3,10 +3,20 @@ package secrets
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"golang.org/x/crypto/chacha20poly1305"
 	"golang.org/x/crypto/curve25519"
+	"regexp"
 )
 
+var (
+	ErrInvalidCiphertext = errors.New("ciphertext is too short")
+	ErrInvalidEncoding   = errors.New("invalid secret encoding")
+)
+
+// BlobRegexp matches secrets encoded with Encode
+var BlobRegexp = regexp.MustCompile(`(?U)\$\$(?P<key>.+):(?P<data>.+)\$\$`)
+
 type Seed []byte
 type PublicKey []byte
 
@@ -52,6 +62,9 @@ func Decrypt(seed Seed, point PublicKey, data []byte) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if len(data) < cipher.NonceSize()+cipher.Overhead() {
+		return "", ErrInvalidCiphertext
+	}
 	out := make([]byte, 0, len(data)-cipher.Overhead()-cipher.NonceSize())
 	nonce := data[:cipher.NonceSize()]
 	encryptedData := data[cipher.NonceSize():]
@@ -62,3 +75,20 @@ func Decrypt(seed Seed, point PublicKey, data []byte) (string, error) {
 func Encode(point PublicKey, data []byte) string {
 	return "$$" + base64.StdEncoding.EncodeToString(point) + ":" + base64.StdEncoding.EncodeToString(data) + "$$"
 }
+
+// Decode parses a secret encoded with Encode into the sender public key and the ciphertext
+func Decode(blob string) (PublicKey, []byte, error) {
+	match := BlobRegexp.FindStringSubmatch(blob)
+	if match == nil || match[0] != blob {
+		return nil, nil, ErrInvalidEncoding
+	}
+	point, err := base64.StdEncoding.DecodeString(match[BlobRegexp.SubexpIndex("key")])
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(match[BlobRegexp.SubexpIndex("data")])
+	if err != nil {
+		return nil, nil, err
+	}
+	return point, data, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestEncryption(t *testing.T) {
@@ -31,3 +32,159 @@ func TestEncryption(t *testing.T) {
 		t.Fatal("data mismatch")
 	}
 }
+
+func TestDecode(t *testing.T) {
+	alice, _ := GenerateKey()
+	bob, _ := GenerateKey()
+	alicePub, _ := PublicKeyFromSeed(alice)
+	bobPub, _ := PublicKeyFromSeed(bob)
+	encrypted, err := Encrypt(alice, bobPub, "secret")
+	if err != nil {
+		t.Fatalf("encryption error: %v", err)
+	}
+	point, data, err := Decode(Encode(alicePub, encrypted))
+	if err != nil {
+		t.Fatalf("Decode returned an error: %v", err)
+	}
+	decrypted, err := Decrypt(bob, point, data)
+	if err != nil || decrypted != "secret" {
+		t.Fatalf("decoded secret can't be decrypted: %v", err)
+	}
+	if _, _, err = Decode("https://example.com/" + Encode(alicePub, encrypted)); err == nil {
+		t.Fatalf("Decode accepted text around the secret")
+	}
+	if _, err = Decrypt(bob, point, data[:10]); err != ErrInvalidCiphertext {
+		t.Fatalf("Decrypt accepted short ciphertext, err = %v", err)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	alice, _ := GenerateKey()
+	bob, _ := GenerateKey()
+	eve, _ := GenerateKey()
+	alicePub, _ := PublicKeyFromSeed(alice)
+	bobPub, _ := PublicKeyFromSeed(bob)
+	encoded, err := EncryptEnvelope([]PublicKey{alicePub, bobPub}, "secret")
+	if err != nil {
+		t.Fatalf("encryption error: %v", err)
+	}
+	for _, seed := range []Seed{alice, bob} {
+		decrypted, err := Unwrap(seed, encoded, "")
+		if err != nil || decrypted != "secret" {
+			t.Fatalf("recipient can't decrypt envelope: %v", err)
+		}
+	}
+	if _, err = Unwrap(eve, encoded, ""); err != ErrNotRecipient {
+		t.Fatalf("Unwrap() error = %v, want %v", err, ErrNotRecipient)
+	}
+
+	data, _ := base64.StdEncoding.DecodeString(encoded[len(EnvelopePrefix)+3 : len(encoded)-2])
+	e, err := ParseEnvelope(data)
+	if err != nil {
+		t.Fatalf("ParseEnvelope returned an error: %v", err)
+	}
+	if len(e.Recipients) != 2 || string(e.Recipients[1].KeyID) != string(KeyID(bobPub)) {
+		t.Fatalf("unexpected recipients: %v", e.Recipients)
+	}
+	// Recipient list is authenticated together with the message
+	data[len(data)-len(e.Ciphertext)-1] ^= 1
+	if _, err = OpenEnvelope(alice, data); err == nil {
+		t.Fatalf("OpenEnvelope accepted tampered envelope")
+	}
+	if _, err = ParseEnvelope(data[:40]); err != ErrInvalidEnvelope {
+		t.Fatalf("ParseEnvelope() error = %v, want %v", err, ErrInvalidEnvelope)
+	}
+	if _, err = EncryptEnvelope(nil, "secret"); err != ErrNoRecipients {
+		t.Fatalf("EncryptEnvelope() error = %v, want %v", err, ErrNoRecipients)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	oldSeed, _ := GenerateKey()
+	newSeed, _ := GenerateKey()
+	sender, _ := GenerateKey()
+	now := time.Now()
+	oldKey, _ := NewKey(oldSeed, now.Add(time.Hour))
+	newKey, _ := NewKey(newSeed, time.Time{})
+	keys := Keyring{newKey, oldKey}
+
+	senderPub, _ := PublicKeyFromSeed(sender)
+	encrypted, _ := Encrypt(sender, oldKey.Public, "legacy")
+	legacy := Encode(senderPub, encrypted)
+	envelope, _ := EncryptEnvelope([]PublicKey{oldKey.Public}, "envelope")
+	for blob, want := range map[string]string{legacy: "legacy", envelope: "envelope"} {
+		got, err := keys.Unwrap(blob, "", now)
+		if err != nil || got != want {
+			t.Fatalf("Unwrap() = %v, %v, want %v", got, err, want)
+		}
+		if _, err = keys.Unwrap(blob, "", now.Add(2*time.Hour)); err != ErrNotRecipient {
+			t.Fatalf("Unwrap() after retirement error = %v, want %v", err, ErrNotRecipient)
+		}
+		// Zero time selects retired keys too
+		if got, _, err := keys.Open(blob, time.Time{}); err != nil || got != want {
+			t.Fatalf("Open() with all keys = %v, %v, want %v", got, err, want)
+		}
+	}
+	if len(keys.Active(now.Add(time.Hour))) != 1 {
+		t.Fatalf("key is active at its retirement time")
+	}
+	if _, err := (Keyring{oldKey}).Unwrap(legacy, "", now.Add(time.Hour)); err != ErrNoKeys {
+		t.Fatalf("Unwrap() error = %v, want %v", err, ErrNoKeys)
+	}
+}
+
+func TestBoundEnvelope(t *testing.T) {
+	seed, _ := GenerateKey()
+	public, _ := PublicKeyFromSeed(seed)
+	encoded, err := EncryptBoundEnvelope([]PublicKey{public}, "API.example.com", "secret")
+	if err != nil {
+		t.Fatalf("encryption error: %v", err)
+	}
+	if got, err := Unwrap(seed, encoded, "api.example.com"); err != nil || got != "secret" {
+		t.Fatalf("Unwrap() = %v, %v, want secret", got, err)
+	}
+	if _, err = Unwrap(seed, encoded, "attacker.com"); err != ErrHostMismatch {
+		t.Fatalf("Unwrap() error = %v, want %v", err, ErrHostMismatch)
+	}
+
+	// Changing the host breaks authentication of the message
+	data, _ := base64.StdEncoding.DecodeString(encoded[len(EnvelopePrefix)+3 : len(encoded)-2])
+	e, err := ParseEnvelope(data)
+	if err != nil || e.Version != EnvelopeVersion2 || e.Host != "api.example.com" {
+		t.Fatalf("ParseEnvelope() = %+v, %v", e, err)
+	}
+	copy(data[2:], "evil")
+	if _, err = OpenEnvelope(seed, data); err == nil {
+		t.Fatalf("OpenEnvelope accepted envelope with a replaced host")
+	}
+	if _, err = EncryptBoundEnvelope([]PublicKey{public}, "", "secret"); err != ErrInvalidHost {
+		t.Fatalf("EncryptBoundEnvelope() error = %v, want %v", err, ErrInvalidHost)
+	}
+	data[0] = 3
+	if _, err = ParseEnvelope(data); err != ErrUnsupportedEnvelope {
+		t.Fatalf("ParseEnvelope() error = %v, want %v", err, ErrUnsupportedEnvelope)
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		binding string
+		host    string
+		want    bool
+	}{
+		{"api.example.com", "api.example.com", true},
+		{"api.example.com", "API.example.com", true},
+		{"api.example.com", "example.com", false},
+		{"api.example.com", "api.example.com.evil.com", false},
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "evilexample.com", false},
+		{"api.example.com", "", false},
+	}
+	for _, tt := range tests {
+		if got := MatchHost(tt.binding, tt.host); got != tt.want {
+			t.Errorf("MatchHost(%v, %v) = %v, want %v", tt.binding, tt.host, got, tt.want)
+		}
+	}
+}
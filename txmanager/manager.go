@@ -0,0 +1,303 @@
+package txmanager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/orakurudata/crystal-ball/configuration"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReceiptPollInterval defines how often receipts of pending transactions are checked
+var ReceiptPollInterval = 1 * time.Second
+
+var (
+	// ErrDropped is returned when none of transaction versions was mined, or only its cancellation was mined
+	ErrDropped = errors.New("transaction was dropped")
+	// ErrNoRevertReason is returned when a reverted transaction succeeds when it's replayed
+	ErrNoRevertReason = errors.New("transaction does not revert when replayed")
+)
+
+// Backend contains methods of the Web3 endpoint that are used by the Manager
+type Backend interface {
+	bind.ContractCaller
+	bind.ContractTransactor
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Manager signs and sends transactions on behalf of a single account.
+// It allocates nonces locally, so transactions can be sent without waiting for previous ones to be mined,
+// and replaces transactions that are stuck in the pool with higher priced ones.
+type Manager struct {
+	backend Backend
+	key     *ecdsa.PrivateKey
+	address common.Address
+	signer  types.Signer
+	gas     configuration.Gas
+
+	mutex       sync.Mutex
+	nonce       uint64
+	nonceLoaded bool
+}
+
+func NewManager(backend Backend, key *ecdsa.PrivateKey, chainID *big.Int, gas configuration.Gas) *Manager {
+	return &Manager{
+		backend: backend,
+		key:     key,
+		address: crypto.PubkeyToAddress(key.PublicKey),
+		signer:  types.NewEIP155Signer(chainID),
+		gas:     gas,
+	}
+}
+
+// Address returns address of the account that sends transactions
+func (m *Manager) Address() common.Address {
+	return m.address
+}
+
+// Send builds a transaction using build function, signs it and sends it to the network.
+// Options passed to build already contain nonce and gas price, and build must not send the transaction by itself.
+// Until deadline, transaction is replaced with a higher priced one every ReplaceAfter.
+// If it is still not mined after deadline, it is canceled. Zero deadline means that transaction is never canceled.
+func (m *Manager) Send(ctx context.Context, deadline time.Time, build func(opts *bind.TransactOpts) (*types.Transaction, error)) (*Transaction, error) {
+	gasPrice, err := m.gasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.nonceLoaded {
+		m.nonce, err = m.backend.PendingNonceAt(ctx, m.address)
+		if err != nil {
+			return nil, err
+		}
+		m.nonceLoaded = true
+	}
+	opts := &bind.TransactOpts{
+		From:     m.address,
+		Nonce:    new(big.Int).SetUint64(m.nonce),
+		Signer:   m.sign,
+		GasPrice: gasPrice,
+		Context:  ctx,
+		NoSend:   true,
+	}
+	tx, err := build(opts)
+	if err != nil {
+		return nil, err
+	}
+	err = m.backend.SendTransaction(ctx, tx)
+	if err != nil {
+		// Either someone else has used this account, or it's unknown whether the transaction was accepted
+		// by one of the endpoints, so nonce has to be loaded from the network again
+		m.nonceLoaded = false
+		return nil, err
+	}
+	m.nonce++
+
+	t := newTransaction(tx)
+	go m.track(t, deadline)
+	return t, nil
+}
+
+func (m *Manager) sign(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	if address != m.address {
+		return nil, bind.ErrNotAuthorized
+	}
+	return types.SignTx(tx, m.signer, m.key)
+}
+
+func (m *Manager) gasPrice(ctx context.Context) (*big.Int, error) {
+	var price *big.Int
+	switch m.gas.Strategy {
+	case configuration.GasStrategyFixed:
+		price = new(big.Int).Set(m.gas.Price)
+	case configuration.GasStrategyMultiplier:
+		suggested, err := m.backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		price, _ = new(big.Float).Mul(new(big.Float).SetInt(suggested), big.NewFloat(m.gas.Multiplier)).Int(nil)
+	default:
+		suggested, err := m.backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		price = suggested
+	}
+	return m.capPrice(price), nil
+}
+
+func (m *Manager) capPrice(price *big.Int) *big.Int {
+	if m.gas.MaxPrice != nil && price.Cmp(m.gas.MaxPrice) > 0 {
+		return new(big.Int).Set(m.gas.MaxPrice)
+	}
+	return price
+}
+
+// bumpPrice returns gas price for a replacement transaction, or nil if price can't be increased anymore
+func (m *Manager) bumpPrice(price *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(price, big.NewInt(100+m.gas.BumpPercent))
+	bumped.Div(bumped, big.NewInt(100))
+	bumped.Add(bumped, big.NewInt(1))
+	if m.gas.MaxPrice != nil && bumped.Cmp(m.gas.MaxPrice) > 0 {
+		return nil
+	}
+	return bumped
+}
+
+// replace re-signs transaction with the same nonce and a higher gas price.
+// If cancel is set, replacement transaction is an empty transfer to ourselves.
+func (m *Manager) replace(ctx context.Context, tx *types.Transaction, cancel bool) (*types.Transaction, error) {
+	price := m.bumpPrice(tx.GasPrice())
+	if price == nil {
+		return nil, nil
+	}
+	var replacement *types.Transaction
+	if cancel {
+		replacement = types.NewTransaction(tx.Nonce(), m.address, big.NewInt(0), 21000, price, nil)
+	} else {
+		replacement = types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), price, tx.Data())
+	}
+	signed, err := m.sign(m.address, replacement)
+	if err != nil {
+		return nil, err
+	}
+	return signed, m.backend.SendTransaction(ctx, signed)
+}
+
+// track waits until one of transaction versions is mined, replacing it if it gets stuck
+func (m *Manager) track(t *Transaction, deadline time.Time) {
+	ticker := time.NewTicker(ReceiptPollInterval)
+	defer ticker.Stop()
+	lastSent := time.Now()
+	var canceledAt time.Time
+	for range ticker.C {
+		if m.checkReceipts(t) {
+			return
+		}
+		// If nonce was consumed, but none of our versions was mined, someone else has used it
+		nonce, err := m.backend.NonceAt(context.Background(), m.address, nil)
+		if err == nil && nonce > t.Nonce() && !m.checkReceipts(t) {
+			m.drop(t)
+			return
+		}
+
+		expired := !deadline.IsZero() && time.Now().After(deadline)
+		canceled := !canceledAt.IsZero()
+		if canceled && time.Since(canceledAt) > 10*m.gas.ReplaceAfter {
+			m.drop(t)
+			return
+		}
+		// Cancellation is sent right after deadline, replacements are sent every ReplaceAfter
+		if time.Since(lastSent) < m.gas.ReplaceAfter && (!expired || canceled) {
+			continue
+		}
+
+		current := t.Current()
+		replacement, err := m.replace(context.Background(), current, expired)
+		lastSent = time.Now()
+		if expired && !canceled {
+			canceledAt = lastSent
+		}
+		if err != nil {
+			if !isNonceError(err) {
+				log.Warn().Err(err).Str("tx", current.Hash().String()).Msg("could not replace transaction")
+			}
+			// Otherwise nonce was already consumed, so one of our versions should be mined soon
+			continue
+		}
+		if replacement == nil {
+			log.Warn().Str("tx", current.Hash().String()).Msg("transaction is stuck, but gas price limit is reached")
+			continue
+		}
+		if expired {
+			log.Warn().Str("tx", current.Hash().String()).Str("replacement", replacement.Hash().String()).
+				Msg("transaction was not mined before deadline, canceling it")
+		} else {
+			log.Info().Str("tx", current.Hash().String()).Str("replacement", replacement.Hash().String()).
+				Str("gas_price", replacement.GasPrice().String()).Msg("transaction is stuck, speeding it up")
+		}
+		t.add(replacement, expired)
+	}
+}
+
+// drop finishes a transaction that was not mined. Its nonce may still be unused, and following transactions
+// would wait for it forever, so nonce has to be loaded from the network again
+func (m *Manager) drop(t *Transaction) {
+	m.mutex.Lock()
+	m.nonceLoaded = false
+	m.mutex.Unlock()
+	t.finish(nil, ErrDropped)
+}
+
+// checkReceipts looks for a receipt of any transaction version, and finishes the transaction if it was mined
+func (m *Manager) checkReceipts(t *Transaction) bool {
+	for _, hash := range t.Hashes() {
+		receipt, err := m.backend.TransactionReceipt(context.Background(), hash)
+		if err == nil {
+			if t.isCancellation(hash) {
+				t.finish(receipt, ErrDropped)
+			} else {
+				t.finish(receipt, nil)
+			}
+			return true
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			log.Warn().Err(err).Str("tx", hash.String()).Msg("could not get transaction receipt")
+		}
+	}
+	return false
+}
+
+// RevertReason replays a reverted transaction in the block it was mined in and returns the revert reason.
+// Errors of the replay that are not reverts, such as network failures, are returned as they are.
+func (m *Manager) RevertReason(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) (string, error) {
+	msg := ethereum.CallMsg{
+		From:     m.address,
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}
+	_, err := m.backend.CallContract(ctx, msg, receipt.BlockNumber)
+	if err == nil {
+		return "", ErrNoRevertReason
+	}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			raw, decodeErr := hexutil.Decode(data)
+			if decodeErr == nil {
+				reason, unpackErr := abi.UnpackRevert(raw)
+				if unpackErr == nil {
+					return reason, nil
+				}
+			}
+		}
+	}
+	if !strings.HasPrefix(err.Error(), "execution reverted") {
+		return "", err
+	}
+	return strings.TrimPrefix(err.Error(), "execution reverted: "), nil
+}
+
+func isNonceError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "nonce too low") || strings.Contains(msg, "already known") ||
+		strings.Contains(msg, "replacement transaction underpriced")
+}
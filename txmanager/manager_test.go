@@ -0,0 +1,265 @@
+package txmanager
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/orakurudata/crystal-ball/configuration"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeBackend struct {
+	mutex    sync.Mutex
+	nonce    uint64
+	mined    uint64
+	price    *big.Int
+	sent     []*types.Transaction
+	sendErr  error
+	callErr  error
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (f *fakeBackend) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (f *fakeBackend) CallContract(context.Context, ethereum.CallMsg, *big.Int) ([]byte, error) {
+	if f.callErr != nil {
+		return nil, f.callErr
+	}
+	return nil, errors.New("execution reverted: test reason")
+}
+
+func (f *fakeBackend) NonceAt(context.Context, common.Address, *big.Int) (uint64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.mined, nil
+}
+
+func (f *fakeBackend) PendingCodeAt(context.Context, common.Address) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (f *fakeBackend) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.nonce, nil
+}
+
+func (f *fakeBackend) SuggestGasPrice(context.Context) (*big.Int, error) {
+	return f.price, nil
+}
+
+func (f *fakeBackend) EstimateGas(context.Context, ethereum.CallMsg) (uint64, error) {
+	return 100000, nil
+}
+
+func (f *fakeBackend) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.sent = append(f.sent, tx)
+	return f.sendErr
+}
+
+func (f *fakeBackend) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if r, ok := f.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (f *fakeBackend) mine(hash common.Hash) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.receipts[hash] = &types.Receipt{TxHash: hash, Status: types.ReceiptStatusSuccessful}
+}
+
+func (f *fakeBackend) sentTransactions() []*types.Transaction {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]*types.Transaction{}, f.sent...)
+}
+
+func newTestManager(t *testing.T, backend *fakeBackend, gas configuration.Gas) *Manager {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	return NewManager(backend, key, big.NewInt(97), gas)
+}
+
+func transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	tx := types.NewTransaction(opts.Nonce.Uint64(), common.Address{1}, big.NewInt(0), 21000, opts.GasPrice, nil)
+	return opts.Signer(opts.From, tx)
+}
+
+func TestManager_gasPrice(t *testing.T) {
+	backend := &fakeBackend{price: big.NewInt(10)}
+	tests := []struct {
+		name string
+		gas  configuration.Gas
+		want int64
+	}{
+		{"test fixed strategy", configuration.Gas{Strategy: configuration.GasStrategyFixed, Price: big.NewInt(7)}, 7},
+		{"test oracle strategy", configuration.Gas{Strategy: configuration.GasStrategyOracle}, 10},
+		{"test multiplier strategy", configuration.Gas{Strategy: configuration.GasStrategyMultiplier, Multiplier: 1.5}, 15},
+		{"test multiplier strategy with cap", configuration.Gas{Strategy: configuration.GasStrategyMultiplier, Multiplier: 3, MaxPrice: big.NewInt(20)}, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, backend, tt.gas)
+			got, err := m.gasPrice(context.Background())
+			if err != nil {
+				t.Fatalf("gasPrice returned an error: %v", err)
+			}
+			if got.Int64() != tt.want {
+				t.Errorf("gasPrice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_bumpPrice(t *testing.T) {
+	m := newTestManager(t, &fakeBackend{}, configuration.Gas{BumpPercent: 15, MaxPrice: big.NewInt(130)})
+	if got := m.bumpPrice(big.NewInt(100)); got.Int64() != 116 {
+		t.Errorf("bumpPrice() = %v, want 116", got)
+	}
+	if got := m.bumpPrice(big.NewInt(116)); got != nil {
+		t.Errorf("bumpPrice() = %v, want nil", got)
+	}
+}
+
+func TestManager_Send(t *testing.T) {
+	ReceiptPollInterval = 10 * time.Millisecond
+	backend := &fakeBackend{nonce: 5, price: big.NewInt(100), receipts: map[common.Hash]*types.Receipt{}}
+	m := newTestManager(t, backend, configuration.Gas{BumpPercent: 15, ReplaceAfter: 50 * time.Millisecond})
+
+	first, err := m.Send(context.Background(), time.Time{}, transfer)
+	if err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+	second, err := m.Send(context.Background(), time.Time{}, transfer)
+	if err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+	if first.Nonce() != 5 || second.Nonce() != 6 {
+		t.Fatalf("wrong nonces allocated, want = 5 and 6, got = %v and %v", first.Nonce(), second.Nonce())
+	}
+
+	backend.mine(second.Hash())
+	receipt, err := second.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("Wait returned an error: %v", err)
+	}
+	if receipt.TxHash != second.Hash() {
+		t.Fatalf("Wait returned receipt of another transaction")
+	}
+
+	// First transaction is stuck, so it has to be replaced with the same nonce and a higher price
+	time.Sleep(100 * time.Millisecond)
+	if len(first.Hashes()) < 2 {
+		t.Fatalf("stuck transaction was not replaced")
+	}
+	current := first.Current()
+	if current.Nonce() != 5 || current.GasPrice().Int64() <= 100 {
+		t.Fatalf("invalid replacement transaction: nonce = %v, price = %v", current.Nonce(), current.GasPrice())
+	}
+	backend.mine(first.Hashes()[0])
+	_, err = first.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("Wait returned an error: %v", err)
+	}
+	if len(backend.sentTransactions()) < 3 {
+		t.Fatalf("expected at least 3 transactions to be sent")
+	}
+}
+
+func TestManager_SendDropped(t *testing.T) {
+	ReceiptPollInterval = 10 * time.Millisecond
+	backend := &fakeBackend{nonce: 3, mined: 3, price: big.NewInt(100), receipts: map[common.Hash]*types.Receipt{}}
+	m := newTestManager(t, backend, configuration.Gas{BumpPercent: 15, ReplaceAfter: time.Minute})
+	tx, err := m.Send(context.Background(), time.Time{}, transfer)
+	if err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+	// Nonce is consumed by a transaction we don't know about
+	backend.mutex.Lock()
+	backend.mined = 4
+	backend.mutex.Unlock()
+	_, err = tx.Wait(context.Background())
+	if !errors.Is(err, ErrDropped) {
+		t.Fatalf("Wait failed, want = ErrDropped, got = %v", err)
+	}
+}
+
+func TestManager_SendAfterDrop(t *testing.T) {
+	ReceiptPollInterval = 10 * time.Millisecond
+	backend := &fakeBackend{nonce: 3, mined: 3, price: big.NewInt(100), receipts: map[common.Hash]*types.Receipt{}}
+	m := newTestManager(t, backend, configuration.Gas{BumpPercent: 15, ReplaceAfter: 5 * time.Millisecond})
+	// Transaction expires right away, and neither it nor its cancellation is ever mined
+	tx, err := m.Send(context.Background(), time.Now(), transfer)
+	if err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+	_, err = tx.Wait(context.Background())
+	if !errors.Is(err, ErrDropped) {
+		t.Fatalf("Wait failed, want = ErrDropped, got = %v", err)
+	}
+	// Nonce of the dropped transaction was never consumed, so it has to be reused
+	next, err := m.Send(context.Background(), time.Time{}, transfer)
+	if err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+	if next.Nonce() != 3 {
+		t.Fatalf("wrong nonce allocated after drop, want = 3, got = %v", next.Nonce())
+	}
+}
+
+func TestManager_SendUncertainError(t *testing.T) {
+	backend := &fakeBackend{nonce: 3, price: big.NewInt(100), receipts: map[common.Hash]*types.Receipt{}}
+	m := newTestManager(t, backend, configuration.Gas{BumpPercent: 15, ReplaceAfter: time.Minute})
+	backend.sendErr = errors.New("i/o timeout")
+	if _, err := m.Send(context.Background(), time.Time{}, transfer); err == nil {
+		t.Fatalf("Send did not return an error")
+	}
+	// One of the endpoints accepted the transaction after all
+	backend.mutex.Lock()
+	backend.sendErr = nil
+	backend.nonce = 4
+	backend.mutex.Unlock()
+	tx, err := m.Send(context.Background(), time.Time{}, transfer)
+	if err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+	if tx.Nonce() != 4 {
+		t.Fatalf("nonce was not reloaded, want = 4, got = %v", tx.Nonce())
+	}
+}
+
+func TestManager_RevertReason(t *testing.T) {
+	m := newTestManager(t, &fakeBackend{}, configuration.Gas{})
+	tx := types.NewTransaction(0, common.Address{1}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	reason, err := m.RevertReason(context.Background(), tx, &types.Receipt{BlockNumber: big.NewInt(1)})
+	if err != nil {
+		t.Fatalf("RevertReason returned an error: %v", err)
+	}
+	if reason != "test reason" {
+		t.Fatalf("RevertReason returned wrong value, want = test reason, got = %v", reason)
+	}
+
+	networkErr := errors.New("connection refused")
+	m = newTestManager(t, &fakeBackend{callErr: networkErr}, configuration.Gas{})
+	reason, err = m.RevertReason(context.Background(), tx, &types.Receipt{BlockNumber: big.NewInt(1)})
+	if err != networkErr || reason != "" {
+		t.Fatalf("RevertReason failed, want = %v, got = %v, %v", networkErr, reason, err)
+	}
+}
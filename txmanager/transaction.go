@@ -0,0 +1,111 @@
+package txmanager
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"sync"
+)
+
+// Transaction tracks all versions of a transaction sent with the same nonce
+type Transaction struct {
+	mutex         sync.Mutex
+	versions      []*types.Transaction
+	cancellations map[common.Hash]bool
+	receipt       *types.Receipt
+	err           error
+	done          chan struct{}
+}
+
+func newTransaction(tx *types.Transaction) *Transaction {
+	return &Transaction{
+		versions:      []*types.Transaction{tx},
+		cancellations: make(map[common.Hash]bool),
+		done:          make(chan struct{}),
+	}
+}
+
+// Nonce returns nonce that is shared by all transaction versions
+func (t *Transaction) Nonce() uint64 {
+	return t.Current().Nonce()
+}
+
+// Hash returns hash of the latest transaction version
+func (t *Transaction) Hash() common.Hash {
+	return t.Current().Hash()
+}
+
+// Current returns the latest transaction version
+func (t *Transaction) Current() *types.Transaction {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.versions[len(t.versions)-1]
+}
+
+// Hashes returns hashes of all transaction versions that were sent
+func (t *Transaction) Hashes() []common.Hash {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	out := make([]common.Hash, len(t.versions))
+	for i, tx := range t.versions {
+		out[i] = tx.Hash()
+	}
+	return out
+}
+
+// Done is closed when one of transaction versions is mined, or when the manager gives up on it
+func (t *Transaction) Done() <-chan struct{} {
+	return t.done
+}
+
+// Wait blocks until transaction is mined and returns its receipt.
+// If the transaction was dropped, ErrDropped is returned along with receipt of the cancellation, if there is one.
+func (t *Transaction) Wait(ctx context.Context) (*types.Receipt, error) {
+	select {
+	case <-t.done:
+		return t.receipt, t.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Mined returns transaction version that was mined, or nil if transaction is still pending or was dropped
+func (t *Transaction) Mined() *types.Transaction {
+	select {
+	case <-t.done:
+	default:
+		return nil
+	}
+	if t.receipt == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, tx := range t.versions {
+		if tx.Hash() == t.receipt.TxHash && !t.cancellations[tx.Hash()] {
+			return tx
+		}
+	}
+	return nil
+}
+
+func (t *Transaction) add(tx *types.Transaction, cancellation bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.versions = append(t.versions, tx)
+	if cancellation {
+		t.cancellations[tx.Hash()] = true
+	}
+}
+
+func (t *Transaction) isCancellation(hash common.Hash) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.cancellations[hash]
+}
+
+func (t *Transaction) finish(receipt *types.Receipt, err error) {
+	t.receipt = receipt
+	t.err = err
+	close(t.done)
+}